@@ -0,0 +1,103 @@
+package httpstat
+
+import "time"
+
+// Aggregate collects the durations of many Results for statistics such as
+// the mean or percentiles of a phase. The phase names of its methods are the
+// ones of PhaseNames, or "Total". The zero value is an empty Aggregate.
+type Aggregate struct {
+	samples map[string][]time.Duration
+	n       int
+	reused  int // the number of Results on a reused connection
+}
+
+// Add adds the durations of r to the Aggregate.
+func (a *Aggregate) Add(r *Result) {
+	if a.samples == nil {
+		a.samples = make(map[string][]time.Duration)
+	}
+	for _, p := range r.phaseDurations() {
+		a.samples[p.name] = append(a.samples[p.name], p.d)
+	}
+	a.samples["Total"] = append(a.samples["Total"], r.total)
+	a.n++
+	if r.reused {
+		a.reused++
+	}
+}
+
+// Len returns the number of Results added.
+func (a *Aggregate) Len() int {
+	return a.n
+}
+
+// Mean returns the mean duration of phase.
+func (a *Aggregate) Mean(phase string) time.Duration {
+	return mean(a.samples[phase])
+}
+
+// StdDev returns the standard deviation of the durations of phase.
+func (a *Aggregate) StdDev(phase string) time.Duration {
+	return stdDev(a.samples[phase])
+}
+
+// Percentile returns the p-th percentile (0-100) duration of phase.
+func (a *Aggregate) Percentile(phase string, p float64) time.Duration {
+	return percentile(a.samples[phase], p)
+}
+
+// PercentileCurve returns points percentiles of phase evenly spaced from
+// p0 to p100, interpolated linearly between the samples, to plot the
+// latency distribution as a smooth curve. points less than 2 is treated as
+// 2. The values are all 0 for an empty Aggregate.
+func (a *Aggregate) PercentileCurve(phase string, points int) []time.Duration {
+	if points < 2 {
+		points = 2
+	}
+	sorted := sortedCopy(a.samples[phase])
+	curve := make([]time.Duration, points)
+	for i := range curve {
+		curve[i] = interpolatedPercentile(sorted, 100*float64(i)/float64(points-1))
+	}
+	return curve
+}
+
+// CoefficientOfVariation returns StdDev / Mean of phase, which tells how
+// stable its latency is independently of its magnitude. A high value means
+// an unstable endpoint. It returns 0 when the mean is zero.
+func (a *Aggregate) CoefficientOfVariation(phase string) float64 {
+	m := a.Mean(phase)
+	if m == 0 {
+		return 0
+	}
+	return float64(a.StdDev(phase)) / float64(m)
+}
+
+// ReuseRate returns the fraction of the Results added which were sent on a
+// reused connection. A low rate with a steady request rate means the
+// connection pool is too small or closes connections too early. It returns
+// 0 for an empty Aggregate.
+func (a *Aggregate) ReuseRate() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return float64(a.reused) / float64(a.n)
+}
+
+// SLOCompliance returns the fraction of the Results added whose phase took
+// at most budget, the number behind an SLO like "99.5% of the requests
+// under 200ms": SLOCompliance("Total", 200*time.Millisecond) >= 0.995. It
+// returns 0 for an empty Aggregate or an unknown phase.
+func (a *Aggregate) SLOCompliance(phase string, budget time.Duration) float64 {
+	samples := a.samples[phase]
+	if len(samples) == 0 {
+		return 0
+	}
+	var met int
+	for _, d := range samples {
+		if d <= budget {
+			met++
+		}
+	}
+	return float64(met) / float64(len(samples))
+}
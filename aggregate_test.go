@@ -0,0 +1,123 @@
+package httpstat
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// aggregateOf returns an Aggregate of Results whose totals are the given
+// milliseconds.
+func aggregateOf(ms ...int) *Aggregate {
+	var a Aggregate
+	for _, v := range ms {
+		a.Add(totalResult(v))
+	}
+	return &a
+}
+
+func TestAggregate(t *testing.T) {
+	a := aggregateOf(2, 4, 4, 4, 5, 5, 7, 9)
+
+	if got := a.Len(); got != 8 {
+		t.Fatalf("Len is %d, want 8", got)
+	}
+	if got, want := a.Mean("Total"), 5*time.Millisecond; got != want {
+		t.Fatalf("Mean is %s, want %s", got, want)
+	}
+	if got, want := a.StdDev("Total"), 2*time.Millisecond; got != want {
+		t.Fatalf("StdDev is %s, want %s", got, want)
+	}
+	if got, want := a.Percentile("Total", 50), 4*time.Millisecond; got != want {
+		t.Fatalf("p50 is %s, want %s", got, want)
+	}
+}
+
+func TestAggregate_CoefficientOfVariation(t *testing.T) {
+	a := aggregateOf(2, 4, 4, 4, 5, 5, 7, 9)
+	if got, want := a.CoefficientOfVariation("Total"), 0.4; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("CoefficientOfVariation is %v, want %v", got, want)
+	}
+
+	// Reused connections have no DNS lookup, its mean is zero.
+	if got := a.CoefficientOfVariation("DNSLookup"); got != 0 {
+		t.Fatalf("CoefficientOfVariation of zero mean is %v, want 0", got)
+	}
+	if got := (&Aggregate{}).CoefficientOfVariation("Total"); got != 0 {
+		t.Fatalf("CoefficientOfVariation of empty Aggregate is %v, want 0", got)
+	}
+}
+
+func TestAggregate_ReuseRate(t *testing.T) {
+	var a Aggregate
+	if got := a.ReuseRate(); got != 0 {
+		t.Fatalf("ReuseRate of empty Aggregate is %v, want 0", got)
+	}
+	for _, reused := range []bool{false, true, true, false, true, true, true, true} {
+		r := totalResult(10)
+		r.reused = reused
+		a.Add(r)
+	}
+	if got := a.ReuseRate(); got != 0.75 {
+		t.Fatalf("ReuseRate is %v, want 0.75", got)
+	}
+}
+
+func TestAggregate_PercentileCurve(t *testing.T) {
+	a := aggregateOf(40, 10, 30, 20, 50, 20, 90)
+
+	curve := a.PercentileCurve("Total", 11)
+	if len(curve) != 11 {
+		t.Fatalf("got %d points, want 11", len(curve))
+	}
+	for i := 1; i < len(curve); i++ {
+		if curve[i] < curve[i-1] {
+			t.Fatalf("curve decreases at point %d: %v", i, curve)
+		}
+	}
+	if curve[0] != 10*time.Millisecond || curve[10] != 90*time.Millisecond {
+		t.Fatalf("curve is %v, want it to go from the min to the max", curve)
+	}
+	// p50 is the median 30ms, p90 interpolates between 50ms and 90ms.
+	if curve[5] != 30*time.Millisecond {
+		t.Fatalf("p50 is %s, want 30ms", curve[5])
+	}
+	if got, want := curve[9], 66*time.Millisecond; got != want {
+		t.Fatalf("p90 is %s, want %s", got, want)
+	}
+
+	if got := (&Aggregate{}).PercentileCurve("Total", 1); len(got) != 2 || got[0] != 0 || got[1] != 0 {
+		t.Fatalf("curve of empty Aggregate is %v, want [0 0]", got)
+	}
+}
+
+func TestAggregate_SLOCompliance(t *testing.T) {
+	// 1000 requests: 990 of 100ms, 5 of exactly 200ms and 5 of 900ms.
+	var a Aggregate
+	for i := 0; i < 1000; i++ {
+		ms := 100
+		switch {
+		case i%200 == 0:
+			ms = 900
+		case i%200 == 1:
+			ms = 200
+		}
+		a.Add(totalResult(ms))
+	}
+
+	if got, want := a.SLOCompliance("Total", 200*time.Millisecond), 0.995; got != want {
+		t.Fatalf("SLOCompliance under 200ms is %v, want %v", got, want)
+	}
+	if got, want := a.SLOCompliance("Total", 150*time.Millisecond), 0.99; got != want {
+		t.Fatalf("SLOCompliance under 150ms is %v, want %v", got, want)
+	}
+	if got := a.SLOCompliance("Total", time.Second); got != 1 {
+		t.Fatalf("SLOCompliance under 1s is %v, want 1", got)
+	}
+	if got := a.SLOCompliance("Teleport", time.Second); got != 0 {
+		t.Fatalf("SLOCompliance of an unknown phase is %v, want 0", got)
+	}
+	if got := (&Aggregate{}).SLOCompliance("Total", time.Second); got != 0 {
+		t.Fatalf("SLOCompliance of an empty Aggregate is %v, want 0", got)
+	}
+}
@@ -0,0 +1,267 @@
+package httpstat
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"net"
+	"time"
+)
+
+// SetupToResponseRatio returns PreTransfer / StartTransfer, the share of the
+// time to first byte spent setting up the connection. A high ratio means the
+// connection setup dominates, a low one that the server is slow. It returns
+// 0 when StartTransfer is zero.
+func (r *Result) SetupToResponseRatio() float64 {
+	if r.StartTransfer <= 0 {
+		return 0
+	}
+	return float64(r.PreTransfer) / float64(r.StartTransfer)
+}
+
+// TLSShareOfSetup returns the TLS handshake time divided by PreTransfer, the
+// share of the connection setup spent in the handshake. A high share points
+// at the TLS setup, like a long certificate chain or RSA keys, rather than
+// at DNS or the network. It returns 0 when PreTransfer is zero.
+func (r *Result) TLSShareOfSetup() float64 {
+	if r.PreTransfer <= 0 {
+		return 0
+	}
+	tls, _ := phaseValue(r, phaseNames[phaseTLSHandshake])
+	return float64(tls) / float64(r.PreTransfer)
+}
+
+// roundTrips returns the number of round trips the protocol needs for the
+// request: one for the TCP handshake, one for TLS 1.3 or a resumed session
+// and two for a full TLS 1.2 handshake, and one for the request itself.
+func (r *Result) roundTrips() int {
+	n := 2
+	if tlsTime, _ := phaseValue(r, phaseNames[phaseTLSHandshake]); tlsTime > 0 {
+		if r.tlsVersion >= tls.VersionTLS13 || r.tlsResumed {
+			n++
+		} else {
+			n += 2
+		}
+	}
+	return n
+}
+
+// MinTheoreticalTotal returns the shortest total the request could have
+// taken: the round trips its protocol needs (TCP, TLS and the request)
+// times the round trip time, estimated by the TCP connection time. The name
+// lookup and the time the server takes are not part of it. It is 0 without
+// a TCP connection, like on a reused connection.
+func (r *Result) MinTheoreticalTotal() time.Duration {
+	rtt, _ := phaseValue(r, phaseNames[phaseTCPConnection])
+	if rtt <= 0 {
+		return 0
+	}
+	return time.Duration(r.roundTrips()) * rtt
+}
+
+// EfficiencyRatio returns MinTheoreticalTotal divided by the total, how
+// close to the optimum the request was: 1 means it only waited for the
+// network, a small ratio that most of the time went elsewhere, usually to
+// the server. It is 0 when either of them is 0.
+func (r *Result) EfficiencyRatio() float64 {
+	best := r.MinTheoreticalTotal()
+	if best <= 0 || r.total <= 0 {
+		return 0
+	}
+	return float64(best) / float64(r.total)
+}
+
+// Normalized returns the duration of each phase divided by the total, a
+// ratio in [0, 1] keyed by phase name, for example to pick the color of a
+// heatmap cell. All ratios are 0 until End is called.
+func (r *Result) Normalized() map[string]float64 {
+	ratios := make(map[string]float64)
+	for _, p := range r.phaseDurations() {
+		if r.total > 0 {
+			ratios[p.name] = float64(p.d) / float64(r.total)
+		} else {
+			ratios[p.name] = 0
+		}
+	}
+	return ratios
+}
+
+// NetworkVsServer splits the total into the share spent on the network
+// (DNS lookup, TCP connection, TLS handshake and content transfer) and the
+// share spent waiting for the server to process the request. Both are 0
+// until End is called.
+func (r *Result) NetworkVsServer() (network, server float64) {
+	if r.total <= 0 {
+		return 0, 0
+	}
+	for _, p := range r.phaseDurations() {
+		if p.name == phaseNames[phaseServerProcessing] {
+			server += float64(p.d)
+		} else {
+			network += float64(p.d)
+		}
+	}
+	return network / float64(r.total), server / float64(r.total)
+}
+
+// UntrackedTime returns the part of the total which no phase accounts for:
+// the total minus the phases up to the first response byte and minus the
+// transfer of the body of the last hop. It is about 0 for a single request.
+// A large value is time spent between hops, like following redirects or
+// waiting before a retry, and is worth investigating. It is 0 until End is
+// called.
+func (r *Result) UntrackedTime() time.Duration {
+	if r.total <= 0 {
+		return 0
+	}
+	var known time.Duration
+	for _, p := range r.phaseDurations() {
+		if p.name != phaseNames[phaseContentTransfer] {
+			known += p.d
+		}
+	}
+	if !r.lastFirstByte.IsZero() && r.transferDone.After(r.lastFirstByte) {
+		known += r.transferDone.Sub(r.lastFirstByte)
+	}
+	if known >= r.total {
+		return 0
+	}
+	return r.total - known
+}
+
+// AnyPhaseOver reports whether the duration of any single phase (not the
+// cumulative one) exceeds d.
+func (r *Result) AnyPhaseOver(d time.Duration) bool {
+	for _, p := range r.phaseDurations() {
+		if p.d > d {
+			return true
+		}
+	}
+	return false
+}
+
+// LooksLocal reports whether the request completed in less than threshold
+// although the remote IP is not a loopback address. Such a trace is too good
+// to be true for a remote host and usually means a local proxy or cache
+// answered, or the measurement is broken. It is false until End is called.
+func (r *Result) LooksLocal(threshold time.Duration) bool {
+	ip := net.ParseIP(r.remoteAddr)
+	if ip == nil || ip.IsLoopback() || r.total <= 0 {
+		return false
+	}
+	return r.total < threshold
+}
+
+// SetupAnomaly reports whether the connection setup looks unusual and why,
+// as a quick hint where to look first. It flags a TLS handshake that took
+// longer than DNS lookup and TCP connection combined, and a DNS lookup
+// which took more than half of the total. The reason is "" when it returns
+// false.
+func (r *Result) SetupAnomaly() (bool, string) {
+	ds := make(map[string]time.Duration)
+	for _, p := range r.phaseDurations() {
+		ds[p.name] = p.d
+	}
+	dns, tcp, tls := ds["DNSLookup"], ds["TCPConnection"], ds["TLSHandshake"]
+
+	if tls > 0 && tls > dns+tcp {
+		return true, fmt.Sprintf("TLS handshake (%s) took longer than DNS lookup and TCP connection combined (%s)", tls, dns+tcp)
+	}
+	if r.total > 0 && dns > r.total/2 {
+		return true, fmt.Sprintf("DNS lookup (%s) took more than half of the total (%s)", dns, r.total)
+	}
+	return false, ""
+}
+
+// Severity returns "ok", "warn" or "critical" depending on the total, for
+// example to pick the color of a status light. The request is "warn" from
+// 500ms and "critical" from 2s, set other thresholds with
+// WithSeverityThresholds. It is "ok" until End is called.
+func (r *Result) Severity() string {
+	warn, critical := r.cfg.warnThreshold, r.cfg.criticalThreshold
+	if warn == 0 {
+		warn = defaultWarnThreshold
+	}
+	if critical == 0 {
+		critical = defaultCriticalThreshold
+	}
+	switch {
+	case r.total >= critical:
+		return "critical"
+	case r.total >= warn:
+		return "warn"
+	}
+	return "ok"
+}
+
+// StatusLabel returns a short label of the outcome of the request for chat
+// notifications: "🔴 failed" when it has an error (see SetError), "🟡 slow"
+// when the total is over the "warn" threshold of Severity (500ms unless set
+// with WithSeverityThresholds) and "🟢 fast" otherwise. With
+// WithPlainStatusLabels the labels are "failed", "slow" and "fast".
+func (r *Result) StatusLabel() string {
+	warn := r.cfg.warnThreshold
+	if warn == 0 {
+		warn = defaultWarnThreshold
+	}
+	emoji, label := "🟢", "fast"
+	switch {
+	case r.err != nil:
+		emoji, label = "🔴", "failed"
+	case r.total >= warn:
+		emoji, label = "🟡", "slow"
+	}
+	if r.cfg.plainLabels {
+		return label
+	}
+	return emoji + " " + label
+}
+
+// warningHints tell what a slow phase usually means.
+var warningHints = map[string]string{
+	"DNSLookup":        "suggests resolver issues",
+	"TCPConnection":    "suggests network latency or packet loss",
+	"TLSHandshake":     "suggests a slow certificate chain or verification",
+	"ServerProcessing": "suggests a slow backend",
+	"ContentTransfer":  "suggests a large body or low bandwidth",
+}
+
+// Warnings returns advice for the phases which took longer than their
+// threshold, in the order of the phases, for example
+//
+//	DNS Lookup took 1.2s (over 1s), which suggests resolver issues
+//
+// The thresholds are set with WithWarningThreshold. It returns nil when no
+// phase is over its threshold.
+func (r *Result) Warnings() []string {
+	var warnings []string
+	for _, p := range r.phaseDurations() {
+		threshold, ok := r.cfg.warningThresholds[p.name]
+		if !ok {
+			threshold, ok = defaultWarningThresholds[p.name]
+		}
+		if !ok || threshold <= 0 || p.d <= threshold {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s took %s (over %s), which %s",
+			phaseLabels[p.name], p.d, threshold, warningHints[p.name]))
+	}
+	return warnings
+}
+
+// Fingerprint returns a hash of the remote IP, the protocol of the response
+// given to SetResponse and the magnitude of every phase, to group Results
+// which look the same. Phases are bucketed by powers of two milliseconds
+// (0, 1, 2-3, 4-7, 8-15 and so on), so jitter within a bucket doesn't
+// change the fingerprint; a phase twice as slow does.
+func (r *Result) Fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s", r.remoteAddr, r.proto)
+	for _, p := range r.phaseDurations() {
+		ms := uint64(p.d / time.Millisecond)
+		fmt.Fprintf(h, "|%s=%d", p.name, bits.Len64(ms))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
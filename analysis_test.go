@@ -0,0 +1,367 @@
+package httpstat
+
+import (
+	"crypto/tls"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetupToResponseRatio(t *testing.T) {
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       20 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 80 * time.Millisecond,
+	}
+	if got, want := result.SetupToResponseRatio(), 0.75; got != want {
+		t.Fatalf("SetupToResponseRatio is %v, want %v", got, want)
+	}
+
+	if got := (&Result{}).SetupToResponseRatio(); got != 0 {
+		t.Fatalf("SetupToResponseRatio of empty result is %v, want 0", got)
+	}
+}
+
+func TestTLSShareOfSetup(t *testing.T) {
+	result := Result{
+		NameLookup:    5 * time.Millisecond,
+		Connect:       25 * time.Millisecond,
+		PreTransfer:   100 * time.Millisecond,
+		StartTransfer: 130 * time.Millisecond,
+	}
+	if got, want := result.TLSShareOfSetup(), 0.75; got != want {
+		t.Fatalf("TLSShareOfSetup is %v, want %v", got, want)
+	}
+
+	plain := Result{NameLookup: 5 * time.Millisecond, Connect: 25 * time.Millisecond, PreTransfer: 25 * time.Millisecond}
+	if got := plain.TLSShareOfSetup(); got != 0 {
+		t.Fatalf("TLSShareOfSetup without TLS is %v, want 0", got)
+	}
+	if got := (&Result{}).TLSShareOfSetup(); got != 0 {
+		t.Fatalf("TLSShareOfSetup of empty result is %v, want 0", got)
+	}
+}
+
+func TestEfficiencyRatio(t *testing.T) {
+	// A 20ms round trip: TCP, two for TLS 1.2 and the request take 80ms.
+	result := Result{
+		NameLookup:    5 * time.Millisecond,
+		Connect:       25 * time.Millisecond,
+		PreTransfer:   65 * time.Millisecond,
+		StartTransfer: 150 * time.Millisecond,
+		total:         200 * time.Millisecond,
+		tlsVersion:    tls.VersionTLS12,
+	}
+	if got, want := result.MinTheoreticalTotal(), 80*time.Millisecond; got != want {
+		t.Fatalf("MinTheoreticalTotal is %s, want %s", got, want)
+	}
+	if got, want := result.EfficiencyRatio(), 0.4; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("EfficiencyRatio is %v, want %v", got, want)
+	}
+
+	// TLS 1.3 saves a round trip, plain HTTP two.
+	result.tlsVersion = tls.VersionTLS13
+	if got, want := result.MinTheoreticalTotal(), 60*time.Millisecond; got != want {
+		t.Fatalf("MinTheoreticalTotal with TLS 1.3 is %s, want %s", got, want)
+	}
+	result.PreTransfer = result.Connect
+	if got, want := result.MinTheoreticalTotal(), 40*time.Millisecond; got != want {
+		t.Fatalf("MinTheoreticalTotal without TLS is %s, want %s", got, want)
+	}
+
+	reused := Result{StartTransfer: 30 * time.Millisecond, total: 31 * time.Millisecond}
+	if reused.MinTheoreticalTotal() != 0 || reused.EfficiencyRatio() != 0 {
+		t.Fatal("expect no estimate without a TCP connection")
+	}
+}
+
+func TestNormalized(t *testing.T) {
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       30 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 160 * time.Millisecond,
+		total:         200 * time.Millisecond,
+	}
+
+	want := map[string]float64{
+		"DNSLookup":        0.05,
+		"TCPConnection":    0.1,
+		"TLSHandshake":     0.15,
+		"ServerProcessing": 0.5,
+		"ContentTransfer":  0.2,
+	}
+	got := result.Normalized()
+	if len(got) != len(want) {
+		t.Fatalf("Normalized is %v, want %v", got, want)
+	}
+	var sum float64
+	for k, v := range want {
+		if math.Abs(got[k]-v) > 1e-9 {
+			t.Fatalf("Normalized[%s] is %v, want %v", k, got[k], v)
+		}
+		sum += got[k]
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Fatalf("ratios sum to %v, want 1", sum)
+	}
+
+	for k, v := range (&Result{}).Normalized() {
+		if v != 0 {
+			t.Fatalf("Normalized[%s] of empty result is %v, want 0", k, v)
+		}
+	}
+}
+
+func TestAnyPhaseOver(t *testing.T) {
+	// Every phase takes 50ms, TLS takes 150ms.
+	result := Result{
+		NameLookup:    50 * time.Millisecond,
+		Connect:       100 * time.Millisecond,
+		PreTransfer:   250 * time.Millisecond,
+		StartTransfer: 300 * time.Millisecond,
+		total:         350 * time.Millisecond,
+	}
+	if !result.AnyPhaseOver(100 * time.Millisecond) {
+		t.Fatal("expect TLS handshake to be over 100ms")
+	}
+	if result.AnyPhaseOver(150 * time.Millisecond) {
+		t.Fatal("expect no phase to be over 150ms")
+	}
+}
+
+func TestLooksLocal(t *testing.T) {
+	fast := Result{remoteAddr: "192.0.2.1", StartTransfer: 300 * time.Microsecond, total: 400 * time.Microsecond}
+	if !fast.LooksLocal(time.Millisecond) {
+		t.Fatal("expect sub-millisecond remote request to look local")
+	}
+
+	loopback := fast
+	loopback.remoteAddr = "127.0.0.1"
+	if loopback.LooksLocal(time.Millisecond) {
+		t.Fatal("expect loopback request not to be flagged")
+	}
+
+	slow := fast
+	slow.total = 40 * time.Millisecond
+	if slow.LooksLocal(time.Millisecond) {
+		t.Fatal("expect 40ms remote request not to be flagged")
+	}
+}
+
+func TestNetworkVsServer(t *testing.T) {
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       30 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 160 * time.Millisecond,
+		total:         200 * time.Millisecond,
+	}
+	network, server := result.NetworkVsServer()
+	if math.Abs(network-0.5) > 1e-9 || math.Abs(server-0.5) > 1e-9 {
+		t.Fatalf("NetworkVsServer is %v, %v, want 0.5, 0.5", network, server)
+	}
+
+	if network, server := (&Result{}).NetworkVsServer(); network != 0 || server != 0 {
+		t.Fatalf("NetworkVsServer of empty result is %v, %v, want 0, 0", network, server)
+	}
+}
+
+func TestSetupAnomaly(t *testing.T) {
+	result := Result{
+		NameLookup:    5 * time.Millisecond,
+		Connect:       15 * time.Millisecond,
+		PreTransfer:   115 * time.Millisecond,
+		StartTransfer: 130 * time.Millisecond,
+		total:         140 * time.Millisecond,
+	}
+	ok, reason := result.SetupAnomaly()
+	if !ok {
+		t.Fatal("expect a TLS dominated setup to be an anomaly")
+	}
+	want := "TLS handshake (100ms) took longer than DNS lookup and TCP connection combined (15ms)"
+	if reason != want {
+		t.Fatalf("reason is %q, want %q", reason, want)
+	}
+
+	result = Result{
+		NameLookup:    80 * time.Millisecond,
+		Connect:       90 * time.Millisecond,
+		PreTransfer:   90 * time.Millisecond,
+		StartTransfer: 100 * time.Millisecond,
+		total:         110 * time.Millisecond,
+	}
+	if ok, reason := result.SetupAnomaly(); !ok || reason != "DNS lookup (80ms) took more than half of the total (110ms)" {
+		t.Fatalf("SetupAnomaly is %v, %q", ok, reason)
+	}
+
+	result = Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       30 * time.Millisecond,
+		PreTransfer:   50 * time.Millisecond,
+		StartTransfer: 150 * time.Millisecond,
+		total:         160 * time.Millisecond,
+	}
+	if ok, reason := result.SetupAnomaly(); ok || reason != "" {
+		t.Fatalf("SetupAnomaly is %v, %q, want false", ok, reason)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	custom := WithSeverityThresholds(100*time.Millisecond, 300*time.Millisecond)
+	cases := []struct {
+		total time.Duration
+		opts  []Option
+		want  string
+	}{
+		{0, nil, "ok"},
+		{200 * time.Millisecond, nil, "ok"},
+		{500 * time.Millisecond, nil, "warn"},
+		{1500 * time.Millisecond, nil, "warn"},
+		{2 * time.Second, nil, "critical"},
+		{50 * time.Millisecond, []Option{custom}, "ok"},
+		{200 * time.Millisecond, []Option{custom}, "warn"},
+		{400 * time.Millisecond, []Option{custom}, "critical"},
+	}
+	for _, tc := range cases {
+		result := Result{total: tc.total}
+		for _, opt := range tc.opts {
+			opt(&result.cfg)
+		}
+		if got := result.Severity(); got != tc.want {
+			t.Fatalf("Severity of %s is %q, want %q", tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestStatusLabel(t *testing.T) {
+	plain := WithPlainStatusLabels()
+	custom := WithSeverityThresholds(100*time.Millisecond, 300*time.Millisecond)
+	failed := errors.New("connection refused")
+	cases := []struct {
+		total time.Duration
+		err   error
+		opts  []Option
+		want  string
+	}{
+		{100 * time.Millisecond, nil, nil, "🟢 fast"},
+		{800 * time.Millisecond, nil, nil, "🟡 slow"},
+		{100 * time.Millisecond, failed, nil, "🔴 failed"},
+		{100 * time.Millisecond, nil, []Option{plain}, "fast"},
+		{800 * time.Millisecond, nil, []Option{plain}, "slow"},
+		{800 * time.Millisecond, failed, []Option{plain}, "failed"},
+		{200 * time.Millisecond, nil, []Option{custom}, "🟡 slow"},
+	}
+	for _, tc := range cases {
+		result := Result{total: tc.total}
+		result.SetError(tc.err)
+		for _, opt := range tc.opts {
+			opt(&result.cfg)
+		}
+		if got := result.StatusLabel(); got != tc.want {
+			t.Fatalf("StatusLabel of %s with error %v is %q, want %q", tc.total, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	slowDNS := Result{
+		NameLookup:    1200 * time.Millisecond,
+		Connect:       1210 * time.Millisecond,
+		PreTransfer:   1230 * time.Millisecond,
+		StartTransfer: 1300 * time.Millisecond,
+		total:         1310 * time.Millisecond,
+	}
+	got := slowDNS.Warnings()
+	want := "DNS Lookup took 1.2s (over 1s), which suggests resolver issues"
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("Warnings are %q, want [%q]", got, want)
+	}
+
+	// A higher threshold silences it, a lower one reports another phase.
+	for _, opt := range []Option{
+		WithWarningThreshold("DNSLookup", 2*time.Second),
+		WithWarningThreshold("ServerProcessing", 50*time.Millisecond),
+	} {
+		opt(&slowDNS.cfg)
+	}
+	got = slowDNS.Warnings()
+	want = "Server Processing took 70ms (over 50ms), which suggests a slow backend"
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("Warnings are %q, want [%q]", got, want)
+	}
+
+	if got := (&Result{}).Warnings(); got != nil {
+		t.Fatalf("Warnings of empty result are %q, want none", got)
+	}
+}
+
+func TestUntrackedTime(t *testing.T) {
+	start := time.Now()
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       20 * time.Millisecond,
+		PreTransfer:   30 * time.Millisecond,
+		StartTransfer: 100 * time.Millisecond,
+		total:         300 * time.Millisecond,
+		start:         start,
+		transferDone:  start.Add(300 * time.Millisecond),
+		// The body of the last hop took 50ms, the phases up to the first
+		// byte 100ms: 150ms are unaccounted for.
+		lastFirstByte: start.Add(250 * time.Millisecond),
+	}
+	if got, want := result.UntrackedTime(), 150*time.Millisecond; got != want {
+		t.Fatalf("UntrackedTime is %s, want %s", got, want)
+	}
+
+	// The phases of a single request add up to the total.
+	result.lastFirstByte = start.Add(100 * time.Millisecond)
+	if got := result.UntrackedTime(); got != 0 {
+		t.Fatalf("UntrackedTime of a single request is %s, want 0", got)
+	}
+
+	if got := (&Result{}).UntrackedTime(); got != 0 {
+		t.Fatalf("UntrackedTime of empty result is %s, want 0", got)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	result := func(dns, server time.Duration, remote string) *Result {
+		return &Result{
+			NameLookup:    dns,
+			Connect:       dns + 10*time.Millisecond,
+			PreTransfer:   dns + 10*time.Millisecond,
+			StartTransfer: dns + 10*time.Millisecond + server,
+			total:         dns + 20*time.Millisecond + server,
+			remoteAddr:    remote,
+			proto:         "HTTP/1.1",
+		}
+	}
+
+	a := result(20*time.Millisecond, 100*time.Millisecond, "192.0.2.1")
+	b := result(22*time.Millisecond, 110*time.Millisecond, "192.0.2.1")
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Fatalf("expect Results differing by jitter to share a fingerprint, got %s and %s",
+			a.Fingerprint(), b.Fingerprint())
+	}
+	if got := a.Fingerprint(); len(got) != 16 || got != a.Fingerprint() {
+		t.Fatalf("Fingerprint is %q, want 16 stable hex digits", got)
+	}
+
+	for _, other := range []*Result{
+		result(20*time.Millisecond, 400*time.Millisecond, "192.0.2.1"),
+		result(20*time.Millisecond, 100*time.Millisecond, "192.0.2.2"),
+	} {
+		if a.Fingerprint() == other.Fingerprint() {
+			t.Fatalf("expect %+v not to share the fingerprint of %+v", other, a)
+		}
+	}
+
+	h2 := result(20*time.Millisecond, 100*time.Millisecond, "192.0.2.1")
+	h2.proto = "HTTP/2.0"
+	if a.Fingerprint() == h2.Fingerprint() {
+		t.Fatal("expect another protocol to change the fingerprint")
+	}
+}
@@ -0,0 +1,238 @@
+package httpstat
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RateSample is the number of body bytes read at an offset from the start
+// of the request.
+type RateSample struct {
+	At    time.Duration
+	Bytes int64
+}
+
+// body records how the response body is read.
+type body struct {
+	io.ReadCloser
+	r        *Result
+	progress func(read int64)
+
+	lastSample time.Time
+	sampled    int64 // bytes read at lastSample
+}
+
+// WrapBody returns body wrapped to record how it is read. Replace the body
+// of the response with it before reading:
+//
+//	res.Body = result.WrapBody(res.Body)
+func (r *Result) WrapBody(rc io.ReadCloser) io.ReadCloser {
+	return r.WrapBodyProgress(rc, nil)
+}
+
+// WrapBodyProgress is like WrapBody but also calls progress with the number
+// of bytes read so far after every read. progress may be nil.
+func (r *Result) WrapBodyProgress(rc io.ReadCloser, progress func(read int64)) io.ReadCloser {
+	return &body{ReadCloser: rc, r: r, progress: progress}
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	now := b.r.now()
+
+	r := b.r
+	if r.firstRead.IsZero() {
+		r.firstRead = now
+	}
+	if keep := r.cfg.bodyPeek - len(r.bodyPeek); keep > 0 && n > 0 {
+		if keep > n {
+			keep = n
+		}
+		r.bodyPeek = append(r.bodyPeek, p[:keep]...)
+	}
+	r.bodyBytes += int64(n)
+	if err == io.EOF {
+		r.bodyEOF = true
+	} else if err != nil {
+		r.recordTLSAlert(err)
+	}
+
+	interval := r.cfg.rateSampleInterval
+	if interval == 0 {
+		interval = defaultRateSampleInterval
+	}
+	due := b.lastSample.IsZero() || now.Sub(b.lastSample) >= interval
+	// Always sample the last bytes at EOF so the samples add up to the body.
+	if n > 0 && due || err == io.EOF && b.sampled != r.bodyBytes {
+		b.lastSample = now
+		b.sampled = r.bodyBytes
+		r.rateSamples = append(r.rateSamples, RateSample{
+			At:    now.Sub(r.bodyStart()),
+			Bytes: r.bodyBytes,
+		})
+	}
+
+	if b.progress != nil {
+		b.progress(r.bodyBytes)
+	}
+	return n, err
+}
+
+// WrapDecoded returns rd, the reader decompressing the body, wrapped to
+// count the decompressed bytes for CompressionRatio.
+func (r *Result) WrapDecoded(rd io.Reader) io.Reader {
+	return &decoded{Reader: rd, r: r}
+}
+
+// decoded counts the bytes read from the decompressed body.
+type decoded struct {
+	io.Reader
+	r *Result
+}
+
+func (d *decoded) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	d.r.decodedBytes += int64(n)
+	return n, err
+}
+
+// DrainAndClose reads the body of res to EOF, recording how it is read like
+// WrapBody unless the body is wrapped already, closes it and ends r. A body
+// which isn't read to EOF keeps the transport from reusing the connection,
+// which makes the next request pay for a new one; this does both the reuse
+// and the timing right in one call:
+//
+//	res, err := client.Do(req)
+//	if err != nil {
+//		return err
+//	}
+//	httpstat.DrainAndClose(res, &result)
+//
+// The error is the one of reading the body. r is ended in any case.
+func DrainAndClose(res *http.Response, r *Result) error {
+	rc := res.Body
+	if b, ok := rc.(*body); !ok || b.r != r {
+		rc = r.WrapBody(rc)
+	}
+	_, err := io.Copy(ioutil.Discard, rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	r.End(r.now())
+	return err
+}
+
+// bodyStart returns the time body offsets are relative to: the start of the
+// request, or the first read when the request wasn't traced.
+func (r *Result) bodyStart() time.Time {
+	if r.start.IsZero() {
+		return r.firstRead
+	}
+	return r.start
+}
+
+// RateSamples returns the number of body bytes read over time, which shows
+// how the download rate changed. It needs the body to be wrapped by WrapBody
+// and samples at most once per interval set by WithRateSampleInterval.
+func (r *Result) RateSamples() []RateSample {
+	return r.rateSamples
+}
+
+// BodyFullyRead reports whether the body was read to EOF. When it wasn't,
+// for example because the caller closed it once it had the headers,
+// ContentTransfer and the total only cover part of the download. It needs
+// the body to be wrapped by WrapBody and is false when it isn't.
+func (r *Result) BodyFullyRead() bool {
+	return r.bodyEOF
+}
+
+// BodyPeek returns the first bytes of the body, as many as set with
+// WithBodyPeek, to see what an unexpected response was about. It needs the
+// body to be wrapped by WrapBody and holds fewer bytes when the body is
+// shorter or wasn't read that far.
+func (r *Result) BodyPeek() []byte {
+	return r.bodyPeek
+}
+
+// MaxTransferStall returns the largest gap between consecutive rate samples,
+// the longest time the download made no progress. A long stall in the
+// middle of a download usually means packet loss and retransmission. It
+// needs the body to be wrapped by WrapBody (or WrapBodyProgress) and can be
+// no finer than the interval of WithRateSampleInterval.
+func (r *Result) MaxTransferStall() time.Duration {
+	var max time.Duration
+	for i := 1; i < len(r.rateSamples); i++ {
+		if gap := r.rateSamples[i].At - r.rateSamples[i-1].At; gap > max {
+			max = gap
+		}
+	}
+	return max
+}
+
+// requestBody counts the bytes of a request body read by the transport.
+type requestBody struct {
+	io.ReadCloser
+	r *Result
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.r.requestBytes, int64(n))
+	return n, err
+}
+
+// WrapRequestBody replaces the body of req with one which counts the bytes
+// sent, see RequestBytes. Call it before sending the request. Transport does
+// it for every request.
+func (r *Result) WrapRequestBody(req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = &requestBody{ReadCloser: req.Body, r: r}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			rc, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &requestBody{ReadCloser: rc, r: r}, nil
+		}
+	}
+}
+
+// RequestBytes returns the number of request body bytes sent, including the
+// ones sent again when the body is replayed for a redirect. It needs the
+// body to be wrapped by WrapRequestBody and is 0 for requests without one.
+func (r *Result) RequestBytes() int64 {
+	return atomic.LoadInt64(&r.requestBytes)
+}
+
+// HeaderToBodyGap returns the time from the first response byte to the end
+// of the first read of the body, which shows a server flushing the headers
+// early and buffering the body. It needs the body to be wrapped by WrapBody
+// and is 0 when it isn't.
+func (r *Result) HeaderToBodyGap() time.Duration {
+	firstByte := r.timeline[milestoneGotFirstResponseByte]
+	if r.firstRead.IsZero() || firstByte.IsZero() || r.firstRead.Before(firstByte) {
+		return 0
+	}
+	return r.firstRead.Sub(firstByte)
+}
+
+// EstimatedBDP returns the bandwidth-delay product of the connection in
+// bytes: the throughput of the body (its size over ContentTransfer) times the
+// round trip time, approximated by the TCP connection time. A TCP window
+// smaller than it can't keep a high latency link busy. It needs the body to
+// be wrapped by WrapBody and is 0 without a body, a transfer time or a TCP
+// connection time, like on a reused connection.
+func (r *Result) EstimatedBDP() int64 {
+	transfer := r.ContentTransfer()
+	rtt, _ := phaseValue(r, phaseNames[phaseTCPConnection])
+	if r.bodyBytes <= 0 || transfer <= 0 || rtt <= 0 {
+		return 0
+	}
+	return int64(float64(r.bodyBytes) * float64(rtt) / float64(transfer))
+}
@@ -0,0 +1,315 @@
+package httpstat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkedServer writes n chunks of size bytes with gap between them.
+func chunkedServer(n, size int, gap time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				time.Sleep(gap)
+			}
+			io.WriteString(w, strings.Repeat("x", size))
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestRateSamples(t *testing.T) {
+	srv := chunkedServer(4, 1024, 30*time.Millisecond)
+	defer srv.Close()
+
+	var result Result
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	ctx := WithHTTPStat(context.Background(), &result, WithRateSampleInterval(10*time.Millisecond))
+	res, err := DefaultClient().Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+
+	var progress []int64
+	res.Body = result.WrapBodyProgress(res.Body, func(read int64) {
+		progress = append(progress, read)
+	})
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+
+	samples := result.RateSamples()
+	if len(samples) < 4 {
+		t.Fatalf("got %d samples, want at least 4: %v", len(samples), samples)
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].At <= samples[i-1].At || samples[i].Bytes <= samples[i-1].Bytes {
+			t.Fatalf("expect samples to increase: %v", samples)
+		}
+	}
+	if last := samples[len(samples)-1]; last.Bytes != 4*1024 {
+		t.Fatalf("last sample has %d bytes, want %d", last.Bytes, 4*1024)
+	}
+	if got := progress[len(progress)-1]; got != 4*1024 {
+		t.Fatalf("progress reported %d bytes, want %d", got, 4*1024)
+	}
+}
+
+func TestRequestBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	payload := strings.Repeat("x", 10000)
+	req, err := http.NewRequest("POST", srv.URL, strings.NewReader(payload))
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	req = req.WithContext(WithHTTPStat(req.Context(), &result))
+	result.WrapRequestBody(req)
+
+	res, err := DefaultClient().Do(req)
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if got, want := result.RequestBytes(), int64(len(payload)); got != want {
+		t.Fatalf("RequestBytes is %d, want %d", got, want)
+	}
+
+	// GET has no body.
+	var get Result
+	req = NewRequest(t, srv.URL, &get)
+	get.WrapRequestBody(req)
+	res, err = DefaultClient().Do(req)
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if got := get.RequestBytes(); got != 0 {
+		t.Fatalf("RequestBytes of GET is %d, want 0", got)
+	}
+}
+
+func TestHeaderToBodyGap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "body")
+	}))
+	defer srv.Close()
+
+	var result Result
+	res, err := DefaultClient().Do(NewRequest(t, srv.URL, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if got := result.HeaderToBodyGap(); got != 0 {
+		t.Fatalf("HeaderToBodyGap before the body is read is %s, want 0", got)
+	}
+
+	res.Body = result.WrapBody(res.Body)
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+
+	if got := result.HeaderToBodyGap(); got < 100*time.Millisecond {
+		t.Fatalf("HeaderToBodyGap is %s, want at least 100ms", got)
+	}
+}
+
+func TestMaxTransferStall(t *testing.T) {
+	result := Result{rateSamples: []RateSample{
+		{At: 10 * time.Millisecond, Bytes: 1024},
+		{At: 20 * time.Millisecond, Bytes: 2048},
+		{At: 320 * time.Millisecond, Bytes: 3072},
+		{At: 330 * time.Millisecond, Bytes: 4096},
+	}}
+	if got, want := result.MaxTransferStall(), 300*time.Millisecond; got != want {
+		t.Fatalf("MaxTransferStall is %s, want %s", got, want)
+	}
+	if got := (&Result{}).MaxTransferStall(); got != 0 {
+		t.Fatalf("MaxTransferStall without samples is %s, want 0", got)
+	}
+
+	// A server pausing in the middle of the body.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i, gap := range []time.Duration{0, 10 * time.Millisecond, 150 * time.Millisecond, 10 * time.Millisecond} {
+			time.Sleep(gap)
+			io.WriteString(w, strings.Repeat("x", 1024*(i+1)))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	var traced Result
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	ctx := WithHTTPStat(context.Background(), &traced, WithRateSampleInterval(time.Millisecond))
+	res, err := DefaultClient().Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	res.Body = traced.WrapBodyProgress(res.Body, func(int64) {})
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	traced.End(time.Now())
+
+	if got := traced.MaxTransferStall(); got < 150*time.Millisecond {
+		t.Fatalf("MaxTransferStall is %s, want at least 150ms", got)
+	}
+}
+
+func TestWithBodyPeek(t *testing.T) {
+	srv := chunkedServer(3, 16, time.Millisecond)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		opts []Option
+		want int
+	}{
+		{[]Option{WithBodyPeek(20)}, 20},
+		{[]Option{WithBodyPeek(100)}, 48},
+		{nil, 0},
+	} {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed:", err)
+		}
+		var result Result
+		res, err := DefaultClient().Do(req.WithContext(WithHTTPStat(req.Context(), &result, tc.opts...)))
+		if err != nil {
+			t.Fatal("client.Do failed:", err)
+		}
+		res.Body = result.WrapBody(res.Body)
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal("ReadAll failed:", err)
+		}
+
+		if len(body) != 48 {
+			t.Fatalf("read %d bytes, want the whole body of 48", len(body))
+		}
+		if got := result.BodyPeek(); string(got) != string(body[:tc.want]) {
+			t.Fatalf("BodyPeek is %q, want %q", got, body[:tc.want])
+		}
+	}
+}
+
+func TestBodyFullyRead(t *testing.T) {
+	srv := chunkedServer(4, 1024, 10*time.Millisecond)
+	defer srv.Close()
+
+	for _, full := range []bool{true, false} {
+		var result Result
+		res, err := DefaultClient().Do(NewRequest(t, srv.URL, &result))
+		if err != nil {
+			t.Fatal("client.Do failed:", err)
+		}
+		res.Body = result.WrapBody(res.Body)
+		if full {
+			_, err = io.Copy(ioutil.Discard, res.Body)
+		} else {
+			_, err = io.ReadFull(res.Body, make([]byte, 100))
+		}
+		if err != nil {
+			t.Fatal("reading the body failed:", err)
+		}
+		res.Body.Close()
+		result.End(time.Now())
+
+		if got := result.BodyFullyRead(); got != full {
+			t.Fatalf("BodyFullyRead is %v, want %v", got, full)
+		}
+	}
+}
+
+func TestEstimatedBDP(t *testing.T) {
+	// 1 MB in 500ms is 2 MB/s, over a 50ms round trip.
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       60 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 100 * time.Millisecond,
+		total:         600 * time.Millisecond,
+		bodyBytes:     1000000,
+	}
+	if got, want := result.EstimatedBDP(), int64(100000); got != want {
+		t.Fatalf("EstimatedBDP is %d, want %d", got, want)
+	}
+
+	reused := result
+	reused.NameLookup, reused.Connect, reused.PreTransfer = 0, 0, 0
+	if got := reused.EstimatedBDP(); got != 0 {
+		t.Fatalf("EstimatedBDP without a connect time is %d, want 0", got)
+	}
+	if got := (&Result{}).EstimatedBDP(); got != 0 {
+		t.Fatalf("EstimatedBDP of empty result is %d, want 0", got)
+	}
+}
+
+func TestDrainAndClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 64<<10))
+	}))
+	defer srv.Close()
+
+	client := DefaultClient()
+	var first Result
+	res, err := client.Do(NewRequest(t, srv.URL, &first))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if err := DrainAndClose(res, &first); err != nil {
+		t.Fatal("DrainAndClose failed:", err)
+	}
+	if first.Total(time.Now()) <= first.StartTransfer || !first.BodyFullyRead() {
+		t.Fatalf("expect the Result to be ended after the whole body: %+v", first)
+	}
+	if first.bodyBytes != 64<<10 {
+		t.Fatalf("read %d body bytes, want %d", first.bodyBytes, 64<<10)
+	}
+
+	var second Result
+	res, err = client.Do(NewRequest(t, srv.URL, &second))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	// A body wrapped already is not counted twice.
+	res.Body = second.WrapBody(res.Body)
+	if err := DrainAndClose(res, &second); err != nil {
+		t.Fatal("DrainAndClose failed:", err)
+	}
+	if !second.Reused() {
+		t.Fatal("expect the connection drained by DrainAndClose to be reused")
+	}
+	if second.bodyBytes != 64<<10 {
+		t.Fatalf("read %d body bytes, want %d", second.bodyBytes, 64<<10)
+	}
+}
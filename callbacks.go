@@ -0,0 +1,52 @@
+package httpstat
+
+import (
+	"log"
+	"time"
+)
+
+// WithOnPhase sets a callback which is called with the name and duration of
+// each phase when the request moves on to the next one. It runs in the
+// goroutine of the hook, usually one of the transport, so it must be quick.
+func WithOnPhase(fn func(phase string, d time.Duration)) Option {
+	return func(c *config) {
+		c.onPhase = fn
+	}
+}
+
+// WithOnConnect sets a callback which is called when a TCP connect completes
+// or fails, with the arguments of httptrace.ClientTrace.ConnectDone.
+func WithOnConnect(fn func(network, addr string, err error)) Option {
+	return func(c *config) {
+		c.onConnect = fn
+	}
+}
+
+// WithPanicHandler sets the function which is given the value of a panic in
+// a user callback. A panicking callback would otherwise take down the
+// transport goroutine and the whole program with it. By default the panic is
+// logged with the log package; a nil fn drops it silently.
+func WithPanicHandler(fn func(v interface{})) Option {
+	return func(c *config) {
+		c.onPanic = fn
+		c.onPanicSet = true
+	}
+}
+
+// call runs the user callback fn, recovering from a panic in it.
+func (r *Result) call(fn func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if !r.cfg.onPanicSet {
+			log.Printf("[httpstat] recovered from panic in callback: %v", v)
+			return
+		}
+		if r.cfg.onPanic != nil {
+			r.cfg.onPanic(v)
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,82 @@
+package httpstat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCallbackPanic(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var (
+		mu     sync.Mutex
+		panics []interface{}
+		phases []string
+	)
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result,
+		WithOnPhase(func(phase string, d time.Duration) {
+			mu.Lock()
+			phases = append(phases, phase)
+			mu.Unlock()
+			panic("phase " + phase)
+		}),
+		WithOnConnect(func(network, addr string, err error) {
+			panic("connect")
+		}),
+		WithPanicHandler(func(v interface{}) {
+			mu.Lock()
+			panics = append(panics, v)
+			mu.Unlock()
+		}),
+	)
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	res, err := DefaultClient().Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+
+	if result.StartTransfer <= 0 {
+		t.Fatal("expect request to complete")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"TCPConnection", "ServerProcessing", "ContentTransfer"}
+	if len(phases) != len(want) {
+		t.Fatalf("phases are %v, want %v", phases, want)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Fatalf("phases are %v, want %v", phases, want)
+		}
+	}
+	// One panic per phase and one for the connect.
+	if len(panics) != len(want)+1 {
+		t.Fatalf("captured panics are %v, want %d", panics, len(want)+1)
+	}
+}
+
+func TestCallbackPanic_Drop(t *testing.T) {
+	var result Result
+	WithHTTPStat(context.Background(), &result, WithPanicHandler(nil))
+	result.call(func() { panic("dropped") })
+}
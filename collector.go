@@ -0,0 +1,77 @@
+package httpstat
+
+import "sync/atomic"
+
+// StatsCollector counts the requests sent by one or more Transports over
+// their lifetime, unlike Result which covers a single request. The counters
+// are updated atomically so a collector can be shared by all the requests
+// of a busy client without contention. The zero value is ready to use.
+type StatsCollector struct {
+	requests int64
+	reused   int64
+	errors   int64
+	breaks   int64
+
+	// lastReused is 1 when the last request with a response was sent on
+	// a reused connection.
+	lastReused int32
+}
+
+// Stats is a snapshot of the counters of a StatsCollector.
+type Stats struct {
+	// Requests is the number of requests sent, including failed ones.
+	Requests int64
+	// Reused is the number of requests sent on a reused connection.
+	Reused int64
+	// Errors is the number of requests which failed without a response.
+	Errors int64
+	// KeepAliveBreaks is the number of requests sent on a new connection
+	// right after a request sent on a reused one, see KeepAliveBreaks.
+	KeepAliveBreaks int64
+}
+
+// Snapshot returns the current counters. Each counter is read atomically
+// but a snapshot taken while requests complete may mix counts from just
+// before and just after a request.
+func (c *StatsCollector) Snapshot() Stats {
+	return Stats{
+		Requests: atomic.LoadInt64(&c.requests),
+		Reused:   atomic.LoadInt64(&c.reused),
+		Errors:   atomic.LoadInt64(&c.errors),
+
+		KeepAliveBreaks: atomic.LoadInt64(&c.breaks),
+	}
+}
+
+// KeepAliveBreaks returns the number of times a request had to open a new
+// connection after the request before it was sent on a reused one: the
+// pool had a kept alive connection but lost it, for example because the
+// server or a middlebox closed it. A steady count points at intermittent
+// pool failures. Concurrent requests are counted in the order they finish.
+func (c *StatsCollector) KeepAliveBreaks() int {
+	return int(atomic.LoadInt64(&c.breaks))
+}
+
+// record counts a request which was sent with the trace of r and returned
+// err. It does nothing when c is nil.
+func (c *StatsCollector) record(r *Result, err error) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.requests, 1)
+	if r.reused {
+		atomic.AddInt64(&c.reused, 1)
+	}
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+		return
+	}
+
+	var reused int32
+	if r.reused {
+		reused = 1
+	}
+	if atomic.SwapInt32(&c.lastReused, reused) == 1 && reused == 0 {
+		atomic.AddInt64(&c.breaks, 1)
+	}
+}
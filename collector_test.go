@@ -0,0 +1,76 @@
+package httpstat
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestStatsCollector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	const workers, perWorker = 8, 10
+	var stats StatsCollector
+	transport := DefaultTransport()
+	transport.MaxIdleConnsPerHost = workers
+	client := &http.Client{
+		Transport: &Transport{Base: transport, Stats: &stats},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				res, err := client.Get(srv.URL)
+				if err != nil {
+					t.Error("client.Get failed:", err)
+					return
+				}
+				io.Copy(ioutil.Discard, res.Body)
+				res.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := client.Get("http://127.0.0.1:0/"); err == nil {
+		t.Fatal("expect a request to port 0 to fail")
+	}
+
+	got := stats.Snapshot()
+	if got.Requests != workers*perWorker+1 {
+		t.Fatalf("Requests is %d, want %d", got.Requests, workers*perWorker+1)
+	}
+	if got.Errors != 1 {
+		t.Fatalf("Errors is %d, want 1", got.Errors)
+	}
+	if got.Reused == 0 || got.Reused >= got.Requests {
+		t.Fatalf("Reused is %d of %d requests", got.Reused, got.Requests)
+	}
+}
+
+func TestStatsCollector_KeepAliveBreaks(t *testing.T) {
+	var stats StatsCollector
+	for _, reused := range []bool{false, true, false, true, true, false, false, true} {
+		stats.record(&Result{reused: reused}, nil)
+	}
+	// A failed request doesn't break the reuse before it.
+	stats.record(&Result{}, errors.New("connection refused"))
+	stats.record(&Result{reused: true}, nil)
+
+	if got := stats.KeepAliveBreaks(); got != 2 {
+		t.Fatalf("KeepAliveBreaks is %d, want 2", got)
+	}
+	if got := stats.Snapshot().KeepAliveBreaks; got != 2 {
+		t.Fatalf("Snapshot KeepAliveBreaks is %d, want 2", got)
+	}
+}
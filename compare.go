@@ -0,0 +1,81 @@
+package httpstat
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// CompareProtocols sends a GET for url once over HTTP/1.1 and once over
+// HTTP/2, each on a new connection of its own transport, and returns both
+// Results for a side by side comparison. The body of each response is read
+// to the end before the Result is ended.
+//
+// The transports are copies of http.DefaultTransport. It fails when the
+// program replaced it with another RoundTripper than an *http.Transport, or
+// when the server does not negotiate HTTP/2, which for net/http requires an
+// https URL.
+func CompareProtocols(ctx context.Context, url string) (h1 *Result, h2 *Result, err error) {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, nil, fmt.Errorf("httpstat: http.DefaultTransport is a %T, not an *http.Transport", http.DefaultTransport)
+	}
+	return compareProtocols(ctx, url, base)
+}
+
+// compareProtocols is CompareProtocols with the transports derived from base.
+func compareProtocols(ctx context.Context, url string, base *http.Transport) (h1 *Result, h2 *Result, err error) {
+	t1 := base.Clone()
+	t1.ForceAttemptHTTP2 = false
+	// A non nil, empty TLSNextProto disables HTTP/2.
+	t1.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	if t1.TLSClientConfig == nil {
+		t1.TLSClientConfig = &tls.Config{}
+	}
+	t1.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	defer t1.CloseIdleConnections()
+
+	t2 := base.Clone()
+	t2.ForceAttemptHTTP2 = true
+	defer t2.CloseIdleConnections()
+
+	if h1, err = traceProto(ctx, url, t1, 1); err != nil {
+		return nil, nil, err
+	}
+	if h2, err = traceProto(ctx, url, t2, 2); err != nil {
+		return nil, nil, err
+	}
+	return h1, h2, nil
+}
+
+// traceProto sends a traced GET for url with transport and checks that the
+// response came with HTTP major version major.
+func traceProto(ctx context.Context, url string, transport http.RoundTripper, major int) (*Result, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{}
+	req = req.WithContext(WithHTTPStat(ctx, r))
+	res, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	r.SetResponse(res)
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		return nil, err
+	}
+	r.End(time.Now())
+
+	if res.ProtoMajor != major {
+		return nil, fmt.Errorf("httpstat: got %s response, want HTTP/%d", res.Proto, major)
+	}
+	return r, nil
+}
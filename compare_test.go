@@ -0,0 +1,55 @@
+package httpstat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareProtocols(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	StartHTTP2(srv)
+	defer srv.Close()
+
+	base := srv.Client().Transport.(*http.Transport)
+	h1, h2, err := compareProtocols(context.Background(), srv.URL, base)
+	if err != nil {
+		t.Fatal("compareProtocols failed:", err)
+	}
+	for name, r := range map[string]*Result{"h1": h1, "h2": h2} {
+		if !r.Complete() {
+			t.Fatalf("%s result is not complete: %+v", name, r)
+		}
+		if r.TLSVersion() == 0 {
+			t.Fatalf("expect %s result to record the TLS handshake", name)
+		}
+		if r.total <= 0 {
+			t.Fatalf("expect %s result to be ended", name)
+		}
+	}
+}
+
+func TestCompareProtocols_NoHTTP2(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if _, _, err := CompareProtocols(context.Background(), srv.URL); err == nil {
+		t.Fatal("expect an error for a server without HTTP/2")
+	}
+}
+
+func TestCompareProtocols_DefaultTransportReplaced(t *testing.T) {
+	defer func(rt http.RoundTripper) { http.DefaultTransport = rt }(http.DefaultTransport)
+	http.DefaultTransport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("not used")
+	})
+
+	if _, _, err := CompareProtocols(context.Background(), "https://example.com"); err == nil {
+		t.Fatal("expect an error when http.DefaultTransport is not an *http.Transport")
+	}
+}
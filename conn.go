@@ -0,0 +1,78 @@
+package httpstat
+
+import (
+	"net"
+	"time"
+)
+
+// Reused reports whether the request was sent on a connection reused from
+// the pool of the transport (HTTP keep-alive) instead of a new one.
+func (r *Result) Reused() bool {
+	return r.reused
+}
+
+// IdleTime returns how long the reused connection sat idle in the pool
+// before the request took it. It is 0 for a new connection.
+func (r *Result) IdleTime() time.Duration {
+	return r.idleTime
+}
+
+// WarmConnection reports whether the request was sent on a reused
+// connection which was idle for less than maxIdle, which means the pool
+// keeps connections warm for the rate at which requests are sent.
+func (r *Result) WarmConnection(maxIdle time.Duration) bool {
+	return r.reused && r.idleTime < maxIdle
+}
+
+// ColdStartPenalty returns how much longer the connection setup of r (the
+// name lookup, the TCP connection and the TLS handshake, that is
+// PreTransfer) took than the one of baselineWarm, a request sent on a
+// reused connection: the cost of a cold connection. It is 0 when r was sent
+// on a reused connection itself, the setup was not slower or baselineWarm
+// is nil.
+func (r *Result) ColdStartPenalty(baselineWarm *Result) time.Duration {
+	if baselineWarm == nil || r.reused {
+		return 0
+	}
+	if d := r.PreTransfer - baselineWarm.PreTransfer; d > 0 {
+		return d
+	}
+	return 0
+}
+
+// ConnectionWarmth summarizes how much of the connection setup the request
+// skipped:
+//
+//   - "cold": a new connection, with a name lookup, a TCP connection and a
+//     TLS handshake.
+//   - "warm": a connection reused from the pool.
+//   - "hot": a reused connection whose TLS session was resumed too, with a
+//     DNSCached name lookup.
+//
+// A reused connection doesn't look the name up, so "hot" needs the lookup
+// of another hop traced by r, like the one of an earlier redirect. A plain
+// HTTP connection is at most "warm".
+func (r *Result) ConnectionWarmth() string {
+	switch {
+	case r.reused && r.tlsResumed && r.DNSCached():
+		return "hot"
+	case r.reused:
+		return "warm"
+	}
+	return "cold"
+}
+
+// SourceAddressSelected returns the local IP the connection was made from,
+// which is LocalIp under a clearer name: on a host with several interfaces
+// it is the source address the routing policy selected.
+func (r *Result) SourceAddressSelected() string {
+	return r.localAddr
+}
+
+// MatchedPreferredSource reports whether the connection was made from the IP
+// set with WithPreferredSource. It is false when no preferred source was set
+// or the local IP is unknown.
+func (r *Result) MatchedPreferredSource() bool {
+	pref := r.cfg.preferredSource
+	return pref != nil && pref.Equal(net.ParseIP(r.localAddr))
+}
@@ -0,0 +1,131 @@
+package httpstat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWarmConnection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := DefaultClient()
+	first := do(t, client, srv.URL)
+	if first.Reused() || first.WarmConnection(time.Hour) {
+		t.Fatal("expect the first connection to be new")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	second := do(t, client, srv.URL)
+	if !second.Reused() {
+		t.Fatal("expect the second connection to be reused")
+	}
+	if second.IdleTime() < 50*time.Millisecond {
+		t.Fatalf("IdleTime is %s, want at least 50ms", second.IdleTime())
+	}
+	if !second.WarmConnection(time.Second) {
+		t.Fatal("expect a connection idle for less than the budget to be warm")
+	}
+	if second.WarmConnection(10 * time.Millisecond) {
+		t.Fatal("expect a connection idle for more than the budget not to be warm")
+	}
+}
+
+func TestMatchedPreferredSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		preferred string
+		want      bool
+	}{
+		{"127.0.0.1", true},
+		{"192.0.2.1", false},
+		{"", false},
+	} {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed:", err)
+		}
+		var result Result
+		var opts []Option
+		if tc.preferred != "" {
+			opts = append(opts, WithPreferredSource(tc.preferred))
+		}
+		res, err := DefaultClient().Do(req.WithContext(WithHTTPStat(req.Context(), &result, opts...)))
+		if err != nil {
+			t.Fatal("client.Do failed:", err)
+		}
+		res.Body.Close()
+
+		if got := result.SourceAddressSelected(); got != "127.0.0.1" {
+			t.Fatalf("SourceAddressSelected is %q, want 127.0.0.1", got)
+		}
+		if got := result.MatchedPreferredSource(); got != tc.want {
+			t.Fatalf("MatchedPreferredSource with %q is %v, want %v", tc.preferred, got, tc.want)
+		}
+	}
+}
+
+func TestColdStartPenalty(t *testing.T) {
+	cold := Result{
+		NameLookup:    20 * time.Millisecond,
+		Connect:       50 * time.Millisecond,
+		PreTransfer:   110 * time.Millisecond,
+		StartTransfer: 160 * time.Millisecond,
+		total:         170 * time.Millisecond,
+	}
+	warm := Result{
+		reused:        true,
+		StartTransfer: 55 * time.Millisecond,
+		total:         65 * time.Millisecond,
+	}
+
+	if got, want := cold.ColdStartPenalty(&warm), 110*time.Millisecond; got != want {
+		t.Fatalf("ColdStartPenalty is %s, want %s", got, want)
+	}
+	if got := warm.ColdStartPenalty(&warm); got != 0 {
+		t.Fatalf("ColdStartPenalty of a warm request is %s, want 0", got)
+	}
+	if got := (&Result{}).ColdStartPenalty(&cold); got != 0 {
+		t.Fatalf("ColdStartPenalty against a slower baseline is %s, want 0", got)
+	}
+	if got := cold.ColdStartPenalty(nil); got != 0 {
+		t.Fatalf("ColdStartPenalty without baseline is %s, want 0", got)
+	}
+}
+
+func TestConnectionWarmth(t *testing.T) {
+	cachedDNS := func(r Result) Result {
+		r.NameLookup = 100 * time.Microsecond
+		r.fired(hookDNS)
+		return r
+	}
+	for _, tc := range []struct {
+		result Result
+		want   string
+	}{
+		{Result{}, "cold"},
+		{Result{tlsConn: true}, "cold"},
+		{Result{tlsConn: true, tlsResumed: true}, "cold"},
+		{cachedDNS(Result{tlsConn: true, tlsResumed: true}), "cold"},
+		{Result{reused: true}, "warm"},
+		{Result{reused: true, tlsConn: true}, "warm"},
+		// Without a cached lookup a resumed session is not enough.
+		{Result{reused: true, tlsConn: true, tlsResumed: true}, "warm"},
+		{cachedDNS(Result{reused: true, tlsConn: true}), "warm"},
+		{cachedDNS(Result{reused: true, tlsConn: true, tlsResumed: true}), "hot"},
+	} {
+		if got := tc.result.ConnectionWarmth(); got != tc.want {
+			t.Fatalf("ConnectionWarmth of reused=%v tls=%v resumed=%v cached DNS=%v is %q, want %q",
+				tc.result.reused, tc.result.tlsConn, tc.result.tlsResumed, tc.result.DNSCached(), got, tc.want)
+		}
+	}
+}
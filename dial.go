@@ -0,0 +1,111 @@
+package httpstat
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DialAttempt is a connection attempt. With Happy Eyeballs (RFC 6555) the
+// transport may try several addresses, concurrently.
+type DialAttempt struct {
+	Network string
+	Addr    string
+
+	// Start is the offset of the attempt from the start of the request
+	// and Duration how long it took. Duration is 0 for an attempt still
+	// running or abandoned when another one succeeded first.
+	Start    time.Duration
+	Duration time.Duration
+
+	// Err is the error of the attempt, nil when it succeeded.
+	Err error
+}
+
+// dialLog records the connection attempts. The hooks of concurrent
+// attempts are called from their own goroutines.
+type dialLog struct {
+	mu       sync.Mutex
+	attempts []dial
+}
+
+// dial is a connection attempt being recorded.
+type dial struct {
+	DialAttempt
+	start time.Time
+	done  bool
+}
+
+func (l *dialLog) start(network, addr string, t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts = append(l.attempts, dial{
+		DialAttempt: DialAttempt{Network: network, Addr: addr},
+		start:       t,
+	})
+}
+
+func (l *dialLog) done(network, addr string, t time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.attempts {
+		d := &l.attempts[i]
+		if d.Network == network && d.Addr == addr && !d.done {
+			d.Duration = t.Sub(d.start)
+			d.Err = err
+			d.done = true
+			return
+		}
+	}
+}
+
+// DialAttempts returns the connection attempts in the order they started.
+// It is empty for a reused connection.
+func (r *Result) DialAttempts() []DialAttempt {
+	return r.dialAttempts(false)
+}
+
+// dialAttempts returns the connection attempts, only the finished ones
+// when finished is true.
+func (r *Result) dialAttempts(finished bool) []DialAttempt {
+	if r.dials == nil {
+		return nil
+	}
+	r.dials.mu.Lock()
+	defer r.dials.mu.Unlock()
+
+	var attempts []DialAttempt
+	for _, d := range r.dials.attempts {
+		if finished && !d.done {
+			continue
+		}
+		a := d.DialAttempt
+		a.Start = d.start.Sub(r.start)
+		attempts = append(attempts, a)
+	}
+	return attempts
+}
+
+// IPv6FellBack reports whether a connection attempt to an IPv6 address
+// failed and one to an IPv4 address succeeded: the client fell back to
+// IPv4, which usually means the IPv6 path to the host is broken.
+func (r *Result) IPv6FellBack() bool {
+	var v6Failed, v4Connected bool
+	for _, a := range r.dialAttempts(true) {
+		host, _, err := net.SplitHostPort(a.Addr)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		switch {
+		case ip.To4() == nil && a.Err != nil:
+			v6Failed = true
+		case ip.To4() != nil && a.Err == nil:
+			v4Connected = true
+		}
+	}
+	return v6Failed && v4Connected
+}
@@ -0,0 +1,42 @@
+package httpstat
+
+import (
+	"context"
+	"errors"
+	"net/http/httptrace"
+	"testing"
+)
+
+func TestIPv6FellBack(t *testing.T) {
+	var result Result
+	trace := httptrace.ContextClientTrace(WithHTTPStat(context.Background(), &result))
+
+	trace.ConnectStart("tcp", "[2001:db8::1]:443")
+	trace.ConnectStart("tcp", "192.0.2.1:443")
+	trace.ConnectDone("tcp", "[2001:db8::1]:443", errors.New("connect: network is unreachable"))
+	trace.ConnectDone("tcp", "192.0.2.1:443", nil)
+
+	attempts := result.DialAttempts()
+	if len(attempts) != 2 {
+		t.Fatalf("got %d dial attempts, want 2", len(attempts))
+	}
+	if attempts[0].Addr != "[2001:db8::1]:443" || attempts[0].Err == nil {
+		t.Fatalf("first attempt is %+v, want the failed IPv6 one", attempts[0])
+	}
+	if attempts[1].Addr != "192.0.2.1:443" || attempts[1].Err != nil {
+		t.Fatalf("second attempt is %+v, want the IPv4 one", attempts[1])
+	}
+	if !result.IPv6FellBack() {
+		t.Fatal("expect a failed IPv6 attempt then an IPv4 one to be a fallback")
+	}
+
+	// Both families connecting, the IPv4 one abandoned, isn't a fallback.
+	var raced Result
+	trace = httptrace.ContextClientTrace(WithHTTPStat(context.Background(), &raced))
+	trace.ConnectStart("tcp", "[2001:db8::1]:443")
+	trace.ConnectDone("tcp", "[2001:db8::1]:443", nil)
+	trace.ConnectStart("tcp", "192.0.2.1:443")
+	if raced.IPv6FellBack() {
+		t.Fatal("expect a working IPv6 connection not to be a fallback")
+	}
+}
@@ -0,0 +1,34 @@
+package httpstat
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// dnsCacheThreshold is the lookup time under which the answer most likely
+// came from a cache (the hosts file, nscd, a local caching resolver) rather
+// than from the network.
+const dnsCacheThreshold = time.Millisecond
+
+// DNSCached reports whether the name lookup was likely answered from a
+// cache, that is it was traced and took less than 1ms. This is a heuristic,
+// httptrace doesn't tell where the answer came from.
+func (r *Result) DNSCached() bool {
+	if hook(atomic.LoadUint32(&r.hooks))&hookDNS == 0 {
+		return false
+	}
+	return r.NameLookup < dnsCacheThreshold
+}
+
+// DNSCacheSavings returns how much faster the name lookup was than the one
+// of baseline, a Result whose lookup went to the network. It returns 0 when
+// the lookup of r was not DNSCached or baseline is nil.
+func (r *Result) DNSCacheSavings(baseline *Result) time.Duration {
+	if baseline == nil || !r.DNSCached() {
+		return 0
+	}
+	if d := baseline.NameLookup - r.NameLookup; d > 0 {
+		return d
+	}
+	return 0
+}
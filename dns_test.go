@@ -0,0 +1,35 @@
+package httpstat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDNSCacheSavings(t *testing.T) {
+	cached := &Result{NameLookup: 200 * time.Microsecond}
+	cached.fired(hookDNS)
+	baseline := &Result{NameLookup: 40 * time.Millisecond}
+	baseline.fired(hookDNS)
+
+	if !cached.DNSCached() {
+		t.Fatal("expect 200us lookup to be cached")
+	}
+	if baseline.DNSCached() {
+		t.Fatal("expect 40ms lookup not to be cached")
+	}
+
+	if got, want := cached.DNSCacheSavings(baseline), 39800*time.Microsecond; got != want {
+		t.Fatalf("DNSCacheSavings is %s, want %s", got, want)
+	}
+	if got := baseline.DNSCacheSavings(baseline); got != 0 {
+		t.Fatalf("DNSCacheSavings of uncached lookup is %s, want 0", got)
+	}
+	if got := cached.DNSCacheSavings(nil); got != 0 {
+		t.Fatalf("DNSCacheSavings without baseline is %s, want 0", got)
+	}
+
+	// No lookup was traced (IP address or reused connection).
+	if (&Result{}).DNSCached() {
+		t.Fatal("expect result without lookup not to be cached")
+	}
+}
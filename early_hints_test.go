@@ -0,0 +1,41 @@
+//go:build go1.19
+// +build go1.19
+
+package httpstat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Servers send a 1xx written with WriteHeader from go1.19 on, earlier ones
+// drop it.
+func TestEarlyHints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	result := do(t, DefaultClient(), srv.URL)
+	if !result.GotEarlyHints() {
+		t.Fatal("expect early hints to be recorded")
+	}
+	at := result.EarlyHintsAt()
+	if at <= 0 || at > result.CumulativeOffsets().Complete-50*time.Millisecond {
+		t.Fatalf("EarlyHintsAt is %s, want before the final response", at)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer plain.Close()
+	if result := do(t, DefaultClient(), plain.URL); result.GotEarlyHints() || result.EarlyHintsAt() != 0 {
+		t.Fatal("expect no early hints")
+	}
+}
@@ -0,0 +1,63 @@
+package httpstat
+
+import "time"
+
+// FlatResult is a Result as a flat struct of exported fields of simple
+// types, with the durations in whole milliseconds, which maps directly to a
+// protobuf message for sending Results over gRPC:
+//
+//	message FlatResult {
+//	  string id = 1;
+//	  int64 name_lookup_ms = 2;
+//	  ...
+//	}
+type FlatResult struct {
+	ID string
+
+	// The cumulative durations of Result, see its doc comment.
+	NameLookupMs    int64
+	ConnectMs       int64
+	PreTransferMs   int64
+	StartTransferMs int64
+	TotalMs         int64
+
+	LocalIP    string
+	RemoteIP   string
+	RemotePort int64
+}
+
+// ToFlat returns r as a FlatResult. The durations are truncated to
+// milliseconds.
+func (r *Result) ToFlat() FlatResult {
+	ms := func(d time.Duration) int64 { return int64(d / time.Millisecond) }
+	return FlatResult{
+		ID:              r.id,
+		NameLookupMs:    ms(r.NameLookup),
+		ConnectMs:       ms(r.Connect),
+		PreTransferMs:   ms(r.PreTransfer),
+		StartTransferMs: ms(r.StartTransfer),
+		TotalMs:         ms(r.total),
+		LocalIP:         r.localAddr,
+		RemoteIP:        r.remoteAddr,
+		RemotePort:      int64(r.remotePort),
+	}
+}
+
+// FromFlat returns the Result of f, for analyzing Results received as
+// FlatResults. ToFlat of it is f, and its phases are the ones of the
+// Result f was made from, to the millisecond. It is ended: the total is the
+// one of f.
+func FromFlat(f FlatResult) *Result {
+	ms := func(n int64) time.Duration { return time.Duration(n) * time.Millisecond }
+	return &Result{
+		id:            f.ID,
+		NameLookup:    ms(f.NameLookupMs),
+		Connect:       ms(f.ConnectMs),
+		PreTransfer:   ms(f.PreTransferMs),
+		StartTransfer: ms(f.StartTransferMs),
+		total:         ms(f.TotalMs),
+		localAddr:     f.LocalIP,
+		remoteAddr:    f.RemoteIP,
+		remotePort:    int(f.RemotePort),
+	}
+}
@@ -0,0 +1,55 @@
+package httpstat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlatResult(t *testing.T) {
+	result := Result{
+		id:            "req-1",
+		NameLookup:    12 * time.Millisecond,
+		Connect:       40 * time.Millisecond,
+		PreTransfer:   95 * time.Millisecond,
+		StartTransfer: 1300 * time.Millisecond,
+		total:         1450 * time.Millisecond,
+		localAddr:     "192.0.2.1",
+		remoteAddr:    "2001:db8::1",
+		remotePort:    443,
+	}
+
+	flat := result.ToFlat()
+	want := FlatResult{
+		ID:              "req-1",
+		NameLookupMs:    12,
+		ConnectMs:       40,
+		PreTransferMs:   95,
+		StartTransferMs: 1300,
+		TotalMs:         1450,
+		LocalIP:         "192.0.2.1",
+		RemoteIP:        "2001:db8::1",
+		RemotePort:      443,
+	}
+	if flat != want {
+		t.Fatalf("ToFlat is %+v, want %+v", flat, want)
+	}
+
+	back := FromFlat(flat)
+	if !reflect.DeepEqual(back.phaseDurations(), result.phaseDurations()) {
+		t.Fatalf("phases after the round trip are %v, want %v", back.phaseDurations(), result.phaseDurations())
+	}
+	if back.ID() != result.ID() || back.LocalIp() != result.LocalIp() ||
+		back.RemoteIP() != result.RemoteIP() || back.RemotePort() != result.RemotePort() {
+		t.Fatalf("FromFlat is %+v, want the addresses and ID of %+v", back, result)
+	}
+	if got := back.ToFlat(); got != flat {
+		t.Fatalf("ToFlat after FromFlat is %+v, want %+v", got, flat)
+	}
+
+	// Durations are truncated to the millisecond.
+	result.NameLookup += 999 * time.Microsecond
+	if got := result.ToFlat().NameLookupMs; got != 12 {
+		t.Fatalf("NameLookupMs is %d, want 12", got)
+	}
+}
@@ -0,0 +1,46 @@
+package httpstat
+
+import (
+	"context"
+	"net/http"
+)
+
+// TracedClient is the client TracedGet sends requests with.
+var TracedClient = http.DefaultClient
+
+// TracedGet is a replacement for http.Get which traces the request. It
+// sends a GET for url with TracedClient and returns the response with its
+// Result. The Result is ended once the body is read to EOF or closed, so
+// read and close the body before using it, as with http.Get:
+//
+//	res, result, err := httpstat.TracedGet("https://example.com/")
+//	if err != nil {
+//		return err
+//	}
+//	io.Copy(ioutil.Discard, res.Body)
+//	res.Body.Close()
+//	log.Printf("%+v", result)
+//
+// On error the Result covers the request up to the failure.
+func TracedGet(url string) (*http.Response, *Result, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &Result{}
+	req = req.WithContext(WithHTTPStat(context.Background(), r))
+	res, err := TracedClient.Do(req)
+	if err != nil {
+		r.SetError(err)
+		r.End(r.now())
+		return nil, r, err
+	}
+
+	r.SetResponse(res)
+	res.Body = &endBody{
+		ReadCloser: r.WrapBody(res.Body),
+		end:        func() { r.End(r.now()) },
+	}
+	return res, r, nil
+}
@@ -0,0 +1,42 @@
+package httpstat
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracedGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	defer func(c *http.Client) { TracedClient = c }(TracedClient)
+	TracedClient = DefaultClient()
+
+	res, result, err := TracedGet(srv.URL)
+	if err != nil {
+		t.Fatal("TracedGet failed:", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+
+	if !result.Complete() {
+		t.Fatalf("expect the Result to be complete: %+v", result)
+	}
+	if result.StartTransfer <= 0 || result.total < result.StartTransfer {
+		t.Fatalf("StartTransfer is %s and total %s", result.StartTransfer, result.total)
+	}
+	if result.RemoteIP() != "127.0.0.1" {
+		t.Fatalf("RemoteIP is %q, want 127.0.0.1", result.RemoteIP())
+	}
+
+	if _, result, err := TracedGet("http://127.0.0.1:0/"); err == nil || result == nil {
+		t.Fatalf("TracedGet of port 0 is %v, %v, want a Result and an error", result, err)
+	}
+}
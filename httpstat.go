@@ -6,7 +6,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"net/http/httptrace"
 	"crypto/tls"
@@ -30,15 +36,179 @@ type Result struct {
 	StartTransfer time.Duration
 	total         time.Duration
 
+	// DNSQueueTime is the time the name lookup waited before the first DNS
+	// query was sent. It is only recorded with a resolver returned by
+	// WrapResolver.
+	DNSQueueTime time.Duration
+
+	// DoHResolveTime is the time spent resolving the host with DNS over
+	// HTTPS. It is only recorded for lookups wrapped by TimeDoH. The lookup
+	// happens before the connection starts, so it is not part of the other
+	// durations.
+	DoHResolveTime time.Duration
+
+	// CertVerifyTime is the time spent in the VerifyPeerCertificate
+	// callback during the TLS handshake. It is only recorded for a callback
+	// wrapped by TimedVerify.
+	CertVerifyTime time.Duration
+
+	// TLSAlert is the description of the TLS alert the server sent when
+	// it failed the handshake or the transfer, like "bad certificate" or
+	// "handshake failure", telling a TLS failure apart from a network
+	// one. An alert during the transfer is only seen by the body returned
+	// by WrapBody.
+	TLSAlert string
+
+	// EffectiveTimeout is the time the request was given to complete: the
+	// tighter of the timeout of the client and the deadline of the context
+	// of the request. It is set by SetRequest and 0 when there is neither.
+	EffectiveTimeout time.Duration
+
+	// ViaSOCKS is true when the connection was dialed through the SOCKS
+	// proxy given to WithSOCKSProxy. The TCP connection is then the one to
+	// the proxy and ProxyConnect its duration.
+	ViaSOCKS     bool
+	ProxyConnect time.Duration
+
+	// ViaConnectTunnel is true when the TLS connection was tunnelled with
+	// CONNECT through the HTTP proxy given to WithConnectProxy, and
+	// TunnelSetup is the time from the connection to the proxy to the
+	// tunnel being established.
+	ViaConnectTunnel bool
+	TunnelSetup      time.Duration
+
+	// HeadersParsed is the time from the start to the response headers
+	// being parsed, a little after StartTransfer. It is set by
+	// SetResponseAt.
+	HeadersParsed time.Duration
+
+	// MSS is the maximum segment size of the TCP connection in bytes. It
+	// is only recorded with WithMSSProbe.
+	MSS int
+
+	// ResponseHeaderBytes is the size of the status line and the headers of
+	// the response as HTTP/1.1 would send them. It is set by SetResponse.
+	ResponseHeaderBytes int64
+
+	// CapturedHeaders holds the response headers asked for with
+	// WithCaptureHeaders, keyed by canonical name. It is set by
+	// SetResponse.
+	CapturedHeaders map[string]string
+
+	// TotalBackoff is the time spent waiting between attempts when the
+	// request is retried. See EndAttempt.
+	TotalBackoff time.Duration
+
+	id           string // the correlation ID, see ID
 	localAddr    string
 	remoteAddr   string
+	remotePort   int
 	start        time.Time // the zero time for the request
 	transferDone time.Time // need to be provided from outside
+
+	hostPort string        // the host:port the connection was requested for
+	connKey  string        // the local and remote addresses of the connection
+	tlsConn  bool          // the connection uses TLS, even when it was reused
+	reused   bool          // the connection was reused
+	idleTime time.Duration // how long the reused connection was idle
+	hooks    uint32        // the hooks which fired, accessed atomically
+
+	poolStats PoolStats // the state of the pool at GotConn, see WithPoolStats
+
+	tlsVersion uint16 // the negotiated TLS version
+	tlsResumed bool   // the TLS session was resumed
+	alpn       string // the negotiated ALPN protocol
+
+	timeline      [numMilestones]time.Time // when each milestone was first reached
+	callerStart   time.Time                // when the caller started timing, set by SetStart
+	lastFirstByte time.Time                // the first response byte of the last hop
+
+	earlyHints time.Time // when a 103 Early Hints response arrived
+	pushes     uint32    // the number of pushed streams, accessed atomically
+
+	// lookupStart is the start of the name lookup in Unix nanoseconds
+	// until a resolver created by WrapResolver sends the first query. It
+	// is accessed atomically as the resolver dials concurrently.
+	lookupStart int64
+
+	// dials are the connection attempts. It is a pointer as it holds a
+	// mutex and Format copies the Result.
+	dials *dialLog
+
+	// events are the hook calls recorded with WithTraceLog, see DumpTrace
+	// and HookSequence. It is nil without it, and a pointer for the same
+	// reason as dials.
+	events *eventLog
+
+	// proxyConnected is when the connection to the CONNECT proxy was made.
+	proxyConnected time.Time
+
+	// dnsServer is the address of the last DNS server the resolver queried,
+	// a string. It is stored atomically as the resolver queries
+	// concurrently.
+	dnsServer atomic.Value
+
+	// err is the error of the request, set by SetError.
+	err error
+
+	// The followings are set by SetResponse
+	statusCode int
+	retryAfter time.Duration
+	noBody     bool   // the response has no body: HEAD or Content-Length 0
+	proto      string // the protocol of the response, like "HTTP/1.1"
+	closed     bool   // the server asked to close the connection
+
+	contentEncoding string // the Content-Encoding of the response
+	uncompressed    bool   // the transport decompressed the body
+
+	// requestBytes is the number of request body bytes read by the
+	// transport. It is accessed atomically as the transport writes the
+	// body in its own goroutine.
+	requestBytes int64
+
+	// The followings are recorded by the body returned by WrapBody
+	bodyBytes   int64
+	firstRead   time.Time
+	rateSamples []RateSample
+	bodyPeek    []byte // the first bytes of the body, see WithBodyPeek
+	bodyEOF     bool   // the body was read to EOF
+
+	decodedBytes int64 // the bytes read from the reader of WrapDecoded
+
+	// attempts is the number of attempts and attemptDone the end of the
+	// previous one in Unix nanoseconds, set by EndAttempt. They are
+	// accessed atomically as concurrent dials start attempts.
+	attempts    int32
+	attemptDone int64
+
+	// phase is the phase in progress in its low bits and when it was
+	// entered in Unix nanoseconds in the others, see enterPhase. It is
+	// accessed atomically since WatchStall reads it while the request is
+	// running.
+	phase int64
+
+	// cancelled is when EndContext saw the context done and
+	// cancelledPhase the phase which was in progress then.
+	cancelled      time.Time
+	cancelledPhase int32
+
+	cfg config
 }
 
 // WithHTTPStat is a wrapper of httptrace.WithClientTrace. It records the
-// time of each httptrace hooks.
-func WithHTTPStat(ctx context.Context, r *Result) context.Context {
+// time of each httptrace hooks. Options change how the time is recorded.
+func WithHTTPStat(ctx context.Context, r *Result, opts ...Option) context.Context {
+	for _, opt := range opts {
+		opt(&r.cfg)
+	}
+	if r.dials == nil {
+		r.dials = &dialLog{}
+	}
+	if r.cfg.traceLog && r.events == nil {
+		r.events = &eventLog{}
+	}
+	ctx = context.WithValue(ctx, resultKey{}, r)
+
 	var (
 		dnsStart    time.Time
 		dnsDone     time.Time
@@ -47,28 +217,75 @@ func WithHTTPStat(ctx context.Context, r *Result) context.Context {
 		tlsDone     time.Time
 		serverStart time.Time
 		serverDone  time.Time
+		getConn     time.Time
 
 		// isTLS is true when connection seems to use TLS
 		isTLS bool
 		// isReused is true when connection is reused (keep-alive)
 		isReused bool
+
+		// mu guards the variables above and the fields of r the hooks
+		// set. The hooks of concurrent dials run at the same time, and
+		// on HTTP/2 WroteRequest runs in the write loop while
+		// GotFirstResponseByte runs in the read loop.
+		mu sync.Mutex
 	)
 
 	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			getConn = r.now()
+			r.mark(milestoneGetConn, getConn)
+			r.events.add(traceEvent{Hook: "GetConn", At: getConn, HostPort: hostPort})
+			r.hostPort = hostPort
+
+			// Every hop of a redirected request asks for a connection.
+			// Measure it from its own start, not from the one of the
+			// previous hop, so the time between hops isn't counted.
+			dnsStart, dnsDone = time.Time{}, time.Time{}
+			tcpStart, tcpDone = time.Time{}, time.Time{}
+			tlsDone = time.Time{}
+			isReused = false
+		},
+
 		DNSStart: func(i httptrace.DNSStartInfo) {
-			dnsStart = time.Now()
+			mu.Lock()
+			defer mu.Unlock()
+
+			dnsStart = r.now()
+			r.mark(milestoneDNSStart, dnsStart)
+			r.events.add(traceEvent{Hook: "DNSStart", At: dnsStart})
+			atomic.StoreInt64(&r.lookupStart, dnsStart.UnixNano())
+			r.beginAttempt(dnsStart)
+			r.enterPhase(phaseDNSLookup, dnsStart)
 			if r.start.IsZero() {
 				r.start = dnsStart
 			}
 		},
 
 		DNSDone: func(i httptrace.DNSDoneInfo) {
-			dnsDone = time.Now()
+			mu.Lock()
+			defer mu.Unlock()
+
+			dnsDone = r.now()
+			r.mark(milestoneDNSDone, dnsDone)
+			r.events.add(traceEvent{Hook: "DNSDone", At: dnsDone})
+			r.fired(hookDNS)
 			r.NameLookup += dnsDone.Sub(dnsStart)
 		},
 
-		ConnectStart: func(_, _ string) {
-			tcpStart = time.Now()
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			tcpStart = r.now()
+			r.dials.start(network, addr, tcpStart)
+			r.mark(milestoneConnectStart, tcpStart)
+			r.events.add(traceEvent{Hook: "ConnectStart", At: tcpStart, Network: network, Addr: addr})
+			r.beginAttempt(tcpStart)
+			r.enterPhase(phaseTCPConnection, tcpStart)
 
 			// When connecting to IP (When no DNS lookup)
 			if dnsStart.IsZero() {
@@ -82,44 +299,133 @@ func WithHTTPStat(ctx context.Context, r *Result) context.Context {
 		},
 
 		ConnectDone: func(network, addr string, err error) {
-			tcpDone = time.Now()
-			r.Connect += tcpDone.Sub(dnsStart)
+			mu.Lock()
+			defer mu.Unlock()
+
+			tcpDone = r.now()
+			r.dials.done(network, addr, tcpDone, err)
+			r.mark(milestoneConnectDone, tcpDone)
+			r.events.add(traceEvent{Hook: "ConnectDone", At: tcpDone, Network: network, Addr: addr, Err: errString(err)})
+			r.fired(hookConnect)
+			if onConnect := r.cfg.onConnect; onConnect != nil {
+				r.call(func() { onConnect(network, addr, err) })
+			}
+			r.socksConnected(addr, tcpDone.Sub(tcpStart), err)
+			r.proxyConnectedAt(addr, tcpDone, err)
+			if r.cfg.curlConnect {
+				r.Connect += tcpDone.Sub(tcpStart)
+			} else {
+				r.Connect += tcpDone.Sub(dnsStart)
+			}
 		},
 
 		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+
 			isTLS = true
+			now := r.now()
+			r.mark(milestoneTLSHandshakeStart, now)
+			r.events.add(traceEvent{Hook: "TLSHandshakeStart", At: now})
+			r.tunnelEstablished(now)
+			r.enterPhase(phaseTLSHandshake, now)
 		},
 
-		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
-			tlsDone = time.Now()
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			tlsDone = r.now()
+			r.mark(milestoneTLSHandshakeDone, tlsDone)
+			r.events.add(traceEvent{Hook: "TLSHandshakeDone", At: tlsDone, TLSVersion: state.Version,
+				Resumed: state.DidResume, ALPN: state.NegotiatedProtocol, Err: errString(err)})
+			r.fired(hookTLS)
+			if err == nil {
+				r.tlsVersion = state.Version
+				r.tlsResumed = state.DidResume
+				r.alpn = state.NegotiatedProtocol
+			} else {
+				r.recordTLSAlert(err)
+			}
 			r.PreTransfer += tlsDone.Sub(dnsStart)
 		},
 
 		GotConn: func(i httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+
 			// Handle when keep alive is used and connection is reused.
 			// DNSStart(Done) and ConnectStart(Done) is skipped
-			gotC := time.Now()
+			gotC := r.now()
+			r.mark(milestoneGotConn, gotC)
+			r.beginAttempt(gotC)
+			r.enterPhase(phaseServerProcessing, gotC)
 			if i.Reused {
 				isReused = true
+				r.reused = true
+				r.idleTime = i.IdleTime
 				if dnsStart.IsZero() {
 					dnsStart = gotC
 					dnsDone = gotC
 				}
 
+				// The request started when it asked for the connection,
+				// which may have waited for the connection to be idle.
 				if r.start.IsZero() {
-					r.start = gotC
+					r.start = getConn
+					if r.start.IsZero() {
+						r.start = gotC
+					}
+				}
+			}
+			tc, tlsConn := i.Conn.(*tls.Conn)
+			if tlsConn {
+				r.tlsConn = true
+				if i.Reused && r.tlsVersion == 0 {
+					// The handshake of the connection was traced by an
+					// earlier request, its state tells how it went.
+					state := tc.ConnectionState()
+					r.tlsResumed = state.DidResume
+					r.alpn = state.NegotiatedProtocol
 				}
 			}
-			if i.Conn.LocalAddr() != nil {
-				r.localAddr = strings.Split(i.Conn.LocalAddr().String(), ":")[0]
+			r.recordMSS(i.Conn)
+			if c := r.cfg.connCounter; c != nil {
+				r.poolStats = c.Stats(r.hostPort)
+			}
+			e := traceEvent{Hook: "GotConn", At: gotC, Reused: i.Reused, IdleTime: i.IdleTime, TLS: tlsConn}
+			if addr := i.Conn.LocalAddr(); addr != nil {
+				e.LocalAddr = addr.String()
+				r.localAddr = r.internAddr(addrHost(e.LocalAddr))
+			}
+			if addr := i.Conn.RemoteAddr(); addr != nil {
+				e.RemoteAddr = addr.String()
+				r.remoteAddr = r.internAddr(addrHost(e.RemoteAddr))
+				r.remotePort = addrPort(e.RemoteAddr)
 			}
-			if i.Conn.RemoteAddr() != nil {
-				r.remoteAddr = strings.Split(i.Conn.RemoteAddr().String(), ":")[0]
+			if e.LocalAddr != "" && e.RemoteAddr != "" {
+				r.connKey = e.LocalAddr + "-" + e.RemoteAddr
 			}
+			r.events.add(e)
+		},
+
+		WroteHeaders: func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			now := r.now()
+			r.mark(milestoneWroteHeaders, now)
+			r.events.add(traceEvent{Hook: "WroteHeaders", At: now})
 		},
 
 		WroteRequest: func(info httptrace.WroteRequestInfo) {
-			serverStart = time.Now()
+			mu.Lock()
+			defer mu.Unlock()
+
+			serverStart = r.now()
+			r.mark(milestoneWroteRequest, serverStart)
+			r.events.add(traceEvent{Hook: "WroteRequest", At: serverStart})
+			r.fired(hookWroteRequest)
 
 			// When client doesn't use DialContext or using old (before go1.7) `net`
 			// pakcage, DNS/TCP/TLS hook is not called.
@@ -147,25 +453,208 @@ func WithHTTPStat(ctx context.Context, r *Result) context.Context {
 				return
 			}
 
-			r.PreTransfer += r.Connect
+			r.PreTransfer += r.connectOffset()
+		},
+
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			now := r.now()
+			r.events.add(traceEvent{Hook: "Got1xxResponse", At: now, Code: code})
+			if code == http.StatusEarlyHints && r.earlyHints.IsZero() {
+				r.earlyHints = now
+			}
+			return nil
 		},
 
 		GotFirstResponseByte: func() {
-			serverDone = time.Now()
+			mu.Lock()
+			defer mu.Unlock()
+
+			serverDone = r.now()
+			r.mark(milestoneGotFirstResponseByte, serverDone)
+			r.events.add(traceEvent{Hook: "GotFirstResponseByte", At: serverDone})
+			r.lastFirstByte = serverDone
+			r.fired(hookFirstByte)
+			r.enterPhase(phaseContentTransfer, serverDone)
 			r.StartTransfer += serverDone.Sub(dnsStart)
 		},
 	})
 
 }
 
-func (r *Result) durations() map[string]time.Duration {
-	return map[string]time.Duration{
-		"NameLookup":    r.NameLookup,
-		"Connect":       r.Connect,
-		"PreTransfer":   r.Connect,
-		"StartTransfer": r.StartTransfer,
-		"Total":         r.total,
+// addrHost returns the host part of a net.Addr string without allocating.
+// Unlike splitting on ":" it also handles IPv6 addresses.
+func addrHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	if i := strings.IndexByte(addr, ':'); i >= 0 {
+		return addr[:i]
+	}
+	return addr
+}
+
+// addrPort returns the port of a net.Addr string or 0.
+func addrPort(addr string) int {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// hook is a set of httptrace hooks.
+type hook uint32
+
+const (
+	hookDNS hook = 1 << iota
+	hookConnect
+	hookTLS
+	hookWroteRequest
+	hookFirstByte
+)
+
+// fired records that h fired. Hooks fire from the read and write loops of
+// the connection concurrently.
+func (r *Result) fired(h hook) {
+	for {
+		old := atomic.LoadUint32(&r.hooks)
+		if atomic.CompareAndSwapUint32(&r.hooks, old, old|uint32(h)) {
+			return
+		}
+	}
+}
+
+// Complete reports whether every phase expected for the request was traced
+// and End was called. The name lookup is not expected when the host is an
+// IP address and the TLS handshake only when the connection uses TLS.
+//
+// A reused connection skips the lookup, connect and handshake so its Result
+// is never Complete.
+func (r *Result) Complete() bool {
+	want := hookConnect | hookWroteRequest | hookFirstByte
+	if host, _, err := net.SplitHostPort(r.hostPort); err != nil || net.ParseIP(host) == nil {
+		want |= hookDNS
+	}
+	if r.tlsConn {
+		want |= hookTLS
+	}
+	return hook(atomic.LoadUint32(&r.hooks))&want == want && !r.transferDone.IsZero()
+}
+
+// PhaseNames returns the names of the phases in the order they happen. They
+// are the keys of Durations.
+func PhaseNames() []string {
+	return []string{
+		phaseNames[phaseDNSLookup],
+		phaseNames[phaseTCPConnection],
+		phaseNames[phaseTLSHandshake],
+		phaseNames[phaseServerProcessing],
+		phaseNames[phaseContentTransfer],
+	}
+}
+
+// Durations returns the duration of each phase (not the cumulative one) keyed
+// by the names of PhaseNames.
+func (r *Result) Durations() map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	for _, p := range r.phaseDurations() {
+		durations[p.name] = p.d
 	}
+	return durations
+}
+
+// ContentTransfer returns the time spent reading the response body, the
+// ContentTransfer entry of Durations. It is exactly 0 for responses without
+// a body, see SetResponse.
+func (r *Result) ContentTransfer() time.Duration {
+	return r.Durations()[phaseNames[phaseContentTransfer]]
+}
+
+// Cumulative holds the offsets from the start of the request at which each
+// milestone was reached.
+type Cumulative struct {
+	DNSLookup   time.Duration
+	TCPConnect  time.Duration
+	TLSComplete time.Duration
+	FirstByte   time.Duration
+	Complete    time.Duration
+}
+
+// CumulativeOffsets returns the cumulative offsets of r. Complete is zero
+// until End is called.
+func (r *Result) CumulativeOffsets() Cumulative {
+	return Cumulative{
+		DNSLookup:   r.NameLookup,
+		TCPConnect:  r.connectOffset(),
+		TLSComplete: r.PreTransfer,
+		FirstByte:   r.StartTransfer,
+		Complete:    r.total,
+	}
+}
+
+// ElapsedTo returns the cumulative offset from the start of the request to
+// the end of the named phase, for example "TLSHandshake" for the offset at
+// which the connection was ready. It is an error when phase is not one of
+// the phase names.
+func (r *Result) ElapsedTo(phase string) (time.Duration, error) {
+	c := r.CumulativeOffsets()
+	switch phase {
+	case phaseNames[phaseDNSLookup]:
+		return c.DNSLookup, nil
+	case phaseNames[phaseTCPConnection]:
+		return c.TCPConnect, nil
+	case phaseNames[phaseTLSHandshake]:
+		return c.TLSComplete, nil
+	case phaseNames[phaseServerProcessing]:
+		return c.FirstByte, nil
+	case phaseNames[phaseContentTransfer]:
+		return c.Complete, nil
+	}
+	return 0, fmt.Errorf("httpstat: unknown phase %q", phase)
+}
+
+// connectOffset returns the offset from the start at which the TCP
+// connection was established, whichever semantics Connect is recorded with.
+func (r *Result) connectOffset() time.Duration {
+	if r.cfg.curlConnect {
+		return r.NameLookup + r.Connect
+	}
+	return r.Connect
+}
+
+// phaseDuration is the duration of a single phase, as opposed to the
+// cumulative durations stored in Result.
+type phaseDuration struct {
+	name string
+	d    time.Duration
+}
+
+// phaseDurations returns the duration of each phase in the order they
+// happen. Negative values, which the cumulative fields can produce when a
+// hook was skipped, are clamped to zero. ContentTransfer is zero until End
+// is called.
+func (r *Result) phaseDurations() []phaseDuration {
+	var transfer time.Duration
+	if r.total > 0 {
+		transfer = r.total - r.StartTransfer
+	}
+	ds := []phaseDuration{
+		{phaseNames[phaseDNSLookup], r.NameLookup},
+		{phaseNames[phaseTCPConnection], r.connectOffset() - r.NameLookup},
+		{phaseNames[phaseTLSHandshake], r.PreTransfer - r.connectOffset()},
+		{phaseNames[phaseServerProcessing], r.StartTransfer - r.PreTransfer},
+		{phaseNames[phaseContentTransfer], transfer},
+	}
+	for i := range ds {
+		if ds[i].d < 0 {
+			ds[i].d = 0
+		}
+	}
+	return ds
 }
 
 func (r *Result) LocalIp() string {
@@ -176,38 +665,161 @@ func (r *Result) RemoteIP() string {
 	return r.remoteAddr
 }
 
-// Format formats stats result.
+// RemotePort returns the port of the remote end of the connection, which is
+// the port the URL implied when it had none. It is 0 when unknown.
+func (r *Result) RemotePort() int {
+	return r.remotePort
+}
+
+// UsedDefaultPort reports whether the connection went to the default port of
+// its scheme, 443 for TLS connections and 80 otherwise.
+func (r *Result) UsedDefaultPort() bool {
+	if r.tlsConn {
+		return r.remotePort == 443
+	}
+	return r.remotePort == 80
+}
+
+// LocalInterface returns the name of the network interface which owns the
+// local IP of the connection. It returns "" when the IP is not known or no
+// interface has it (for example when the lookup fails).
+func (r *Result) LocalInterface() string {
+	ip := net.ParseIP(r.localAddr)
+	if ip == nil {
+		return ""
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Format formats stats result. The %+v verb adds the CapturedHeaders.
 func (r Result) Format(s fmt.State, verb rune) {
+	width := r.formatWidth()
+
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "Name Lookup:    %4d ms\n",
+	fmt.Fprintf(&buf, "Name Lookup:    %*d ms\n", width,
 		int(r.NameLookup/time.Millisecond))
-	fmt.Fprintf(&buf, "Connect:        %4d ms\n",
+	fmt.Fprintf(&buf, "Connect:        %*d ms\n", width,
 		int(r.Connect/time.Millisecond))
-	fmt.Fprintf(&buf, "Pre Transfer:   %4d ms\n",
+	fmt.Fprintf(&buf, "Pre Transfer:   %*d ms\n", width,
 		int(r.PreTransfer/time.Millisecond))
-	fmt.Fprintf(&buf, "Start Transfer: %4d ms\n",
+	fmt.Fprintf(&buf, "Start Transfer: %*d ms\n", width,
 		int(r.StartTransfer/time.Millisecond))
 
 	if r.total > 0 {
-		fmt.Fprintf(&buf, "Total:          %4d ms\n",
+		fmt.Fprintf(&buf, "Total:          %*d ms\n", width,
 			int(r.total/time.Millisecond))
 	} else {
-		fmt.Fprintf(&buf, "Total:          %4s ms\n", "-")
+		fmt.Fprintf(&buf, "Total:          %*s ms\n", width, "-")
+	}
+
+	if s.Flag('+') {
+		if r.id != "" {
+			fmt.Fprintf(&buf, "ID:             %s\n", r.id)
+		}
+		if r.EffectiveTimeout > 0 {
+			fmt.Fprintf(&buf, "Timeout:        %*d ms\n", width,
+				int(r.EffectiveTimeout/time.Millisecond))
+		}
+		if r.closed {
+			io.WriteString(&buf, "Connection:     closed by server\n")
+		}
+		for _, name := range r.cfg.captureHeaders {
+			if v, ok := r.CapturedHeaders[name]; ok {
+				fmt.Fprintf(&buf, "%-15s %s\n", name+":", v)
+			}
+		}
 	}
 	io.WriteString(s, buf.String())
 	return
 }
 
+// formatWidth returns the width of the millisecond values of Format: the one
+// set by WithFormatWidth (4 by default), widened to fit the largest value so
+// that the columns stay aligned.
+func (r *Result) formatWidth() int {
+	width := r.cfg.formatWidth
+	if width <= 0 {
+		width = 4
+	}
+	for _, d := range []time.Duration{r.NameLookup, r.Connect, r.PreTransfer, r.StartTransfer, r.total} {
+		if n := len(strconv.Itoa(int(d / time.Millisecond))); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
 // End sets the time when reading response is done.
-// This must be called after reading response body.
+// This must be called after reading response body. With WithRounding the
+// durations are rounded here.
 func (r *Result) End(t time.Time) {
 	r.transferDone = t
-	// This means result is empty (it does nothing).
+	r.mark(milestoneEnd, t)
+	r.events.add(traceEvent{Hook: "End", At: t})
+	r.enterPhase(phaseDone, t)
+	// When start is zero the result is empty (it does nothing).
 	// Skip setting value(contentTransfer and total will be zero).
-	if r.start.IsZero() {
-		return
+	if !r.start.IsZero() {
+		r.total = r.transferDone.Sub(r.start)
+	}
+	r.pinNoBody()
+	if unit := r.cfg.rounding; unit > 0 {
+		r.NameLookup = r.NameLookup.Round(unit)
+		r.Connect = r.Connect.Round(unit)
+		r.PreTransfer = r.PreTransfer.Round(unit)
+		r.StartTransfer = r.StartTransfer.Round(unit)
+		r.total = r.total.Round(unit)
+	}
+}
+
+// MarkBodyDone is End with the current time, the canonical way to end the
+// Result right after the body was read:
+//
+//	io.Copy(ioutil.Discard, res.Body)
+//	result.MarkBodyDone()
+//
+// It uses the clock of WithClock when set.
+func (r *Result) MarkBodyDone() {
+	r.End(r.now())
+}
+
+// EndContext is like End but also checks whether ctx, the context of the
+// request, is done. When it is the request was cancelled (or timed out) and
+// the phase it was preempted in is recorded, see CancelledAtPhase.
+func (r *Result) EndContext(ctx context.Context, t time.Time) {
+	if ctx.Err() != nil {
+		r.cancelled = t
+		r.cancelledPhase, _ = r.currentPhase()
+	}
+	r.End(t)
+}
+
+// CancelledAtPhase returns the name of the phase which was in progress when
+// the request was cancelled, for example "TCPConnection" when the context
+// timed out while connecting or "ContentTransfer" while reading the body.
+// It needs EndContext and is "" when the request wasn't cancelled or was
+// cancelled before any hook fired.
+func (r *Result) CancelledAtPhase() string {
+	if r.cancelled.IsZero() {
+		return ""
 	}
-	r.total = r.transferDone.Sub(r.start)
+	return phaseNames[r.cancelledPhase]
 }
 
 // Total returns the duration of total http request.
@@ -2,6 +2,8 @@ package httpstat
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +14,7 @@ import (
 	"github.com/ahmetb/go-httpbin.git"
 	"net/http/httptest"
 	"net/http/httputil"
+	"strings"
 )
 
 const (
@@ -41,6 +44,14 @@ func DefaultClient() *http.Client {
 	}
 }
 
+// StartHTTP2 starts srv with TLS negotiating HTTP/2 and makes the client of
+// srv attempt HTTP/2, what httptest.Server.EnableHTTP2 does from go1.14.
+func StartHTTP2(srv *httptest.Server) {
+	srv.TLS = &tls.Config{NextProtos: []string{"h2"}}
+	srv.StartTLS()
+	srv.Client().Transport.(*http.Transport).ForceAttemptHTTP2 = true
+}
+
 func NewRequest(t *testing.T, urlStr string, result *Result) *http.Request {
 	req, err := http.NewRequest("GET", urlStr, nil)
 	if err != nil {
@@ -68,7 +79,7 @@ func TestHTTPStat_HTTPS(t *testing.T) {
 	result.End(time.Now())
 
 
-	for k, d := range result.durations() {
+	for k, d := range result.Durations() {
 		if d <= 0*time.Millisecond {
 			t.Fatalf("expect %s to be non-zero", k)
 		}
@@ -93,7 +104,7 @@ func TestHTTPStat_HTTP(t *testing.T) {
 
 
 	// Except TLS should be non zero
-	durations := result.durations()
+	durations := result.Durations()
 	delete(durations, "TLSHandshake")
 
 	for k, d := range durations {
@@ -224,7 +235,7 @@ func TestHTTPStat_HTTP_Redirects(t *testing.T) {
 
 	fmt.Println(result)
 
-	durations := result.durations()
+	durations := result.Durations()
 	delete(durations, "TLSHandshake")
 
 	for k, d := range durations {
@@ -233,3 +244,315 @@ func TestHTTPStat_HTTP_Redirects(t *testing.T) {
 		}
 	}
 }
+
+func TestLocalInterface(t *testing.T) {
+	result := Result{localAddr: "127.0.0.1"}
+
+	name := result.LocalInterface()
+	if name == "" {
+		t.Fatal("expect loopback interface to be resolved")
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		t.Fatal("InterfaceByName failed:", err)
+	}
+	if iface.Flags&net.FlagLoopback == 0 {
+		t.Fatalf("expect %s to be a loopback interface", name)
+	}
+}
+
+func TestLocalInterface_Unknown(t *testing.T) {
+	for _, addr := range []string{"", "not-an-ip", "192.0.2.255"} {
+		result := Result{localAddr: addr}
+		if name := result.LocalInterface(); name != "" {
+			t.Fatalf("LocalInterface for %q is %q, want empty", addr, name)
+		}
+	}
+}
+
+func TestCumulativeOffsets(t *testing.T) {
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       25 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 90 * time.Millisecond,
+		total:         120 * time.Millisecond,
+	}
+
+	want := Cumulative{
+		DNSLookup:   10 * time.Millisecond,
+		TCPConnect:  25 * time.Millisecond,
+		TLSComplete: 60 * time.Millisecond,
+		FirstByte:   90 * time.Millisecond,
+		Complete:    120 * time.Millisecond,
+	}
+	if got := result.CumulativeOffsets(); got != want {
+		t.Fatalf("CumulativeOffsets is %+v, want %+v", got, want)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	client := srv.Client()
+
+	get := func() *Result {
+		var result Result
+		res, err := client.Do(NewRequest(t, srv.URL, &result))
+		if err != nil {
+			t.Fatal("client.Do failed:", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+			t.Fatal("io.Copy failed:", err)
+		}
+		res.Body.Close()
+		result.End(time.Now())
+		return &result
+	}
+
+	if result := get(); !result.Complete() {
+		t.Fatal("expect first request to be complete")
+	}
+
+	// The second request reuses the connection.
+	if result := get(); result.Complete() {
+		t.Fatal("expect request on a reused connection not to be complete")
+	}
+}
+
+var addrs = []string{"192.0.2.1:443", "10.0.0.1:80", "127.0.0.1:54321", "localhost"}
+
+func TestAddrHost(t *testing.T) {
+	for _, addr := range addrs {
+		want := strings.Split(addr, ":")[0]
+		if got := addrHost(addr); got != want {
+			t.Fatalf("addrHost(%q) is %q, want %q", addr, got, want)
+		}
+	}
+
+	if got, want := addrHost("[2001:db8::1]:443"), "2001:db8::1"; got != want {
+		t.Fatalf("addrHost is %q, want %q", got, want)
+	}
+}
+
+func BenchmarkAddrHost(b *testing.B) {
+	b.Run("Split", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = strings.Split(addrs[i%len(addrs)], ":")[0]
+		}
+	})
+	b.Run("addrHost", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = addrHost(addrs[i%len(addrs)])
+		}
+	})
+}
+
+func TestHTTPStat_FormatterWidth(t *testing.T) {
+	result := Result{
+		NameLookup:    100 * time.Millisecond,
+		Connect:       100 * time.Millisecond,
+		PreTransfer:   100 * time.Millisecond,
+		StartTransfer: 12345 * time.Millisecond,
+		total:         12400 * time.Millisecond,
+	}
+
+	want := `Name Lookup:      100 ms
+Connect:          100 ms
+Pre Transfer:     100 ms
+Start Transfer: 12345 ms
+Total:          12400 ms
+`
+	if got := fmt.Sprintf("%+v", result); want != got {
+		t.Fatalf("expect to be eq:\n\nwant:\n\n%s\ngot:\n\n%s\n", want, got)
+	}
+
+	WithHTTPStat(context.Background(), &result, WithFormatWidth(7))
+	want = `Name Lookup:        100 ms
+Connect:            100 ms
+Pre Transfer:       100 ms
+Start Transfer:   12345 ms
+Total:            12400 ms
+`
+	if got := fmt.Sprintf("%+v", result); want != got {
+		t.Fatalf("expect to be eq:\n\nwant:\n\n%s\ngot:\n\n%s\n", want, got)
+	}
+}
+
+func TestUsedDefaultPort(t *testing.T) {
+	cases := []struct {
+		port   int
+		tls    bool
+		expect bool
+	}{
+		{443, true, true},
+		{80, false, true},
+		{80, true, false},
+		{8443, true, false},
+		{0, false, false},
+	}
+	for _, tc := range cases {
+		result := Result{remotePort: tc.port, tlsConn: tc.tls}
+		if got := result.UsedDefaultPort(); got != tc.expect {
+			t.Fatalf("UsedDefaultPort for port %d (TLS %v) is %v, want %v",
+				tc.port, tc.tls, got, tc.expect)
+		}
+	}
+}
+
+func TestRemotePort_Explicit(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	// The server listens on a port picked by the system, never 443.
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	result := do(t, srv.Client(), srv.URL)
+	if got := result.RemotePort(); got != port {
+		t.Fatalf("RemotePort is %d, want %d", got, port)
+	}
+	if result.UsedDefaultPort() {
+		t.Fatalf("expect :%d not to be the default port", port)
+	}
+}
+
+func TestElapsedTo(t *testing.T) {
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       25 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 90 * time.Millisecond,
+		total:         120 * time.Millisecond,
+	}
+	c := result.CumulativeOffsets()
+
+	for phase, want := range map[string]time.Duration{
+		"DNSLookup":        c.DNSLookup,
+		"TCPConnection":    c.TCPConnect,
+		"TLSHandshake":     c.TLSComplete,
+		"ServerProcessing": c.FirstByte,
+		"ContentTransfer":  c.Complete,
+	} {
+		got, err := result.ElapsedTo(phase)
+		if err != nil {
+			t.Fatalf("ElapsedTo(%q) failed: %s", phase, err)
+		}
+		if got != want {
+			t.Fatalf("ElapsedTo(%q) is %s, want %s", phase, got, want)
+		}
+	}
+
+	if _, err := result.ElapsedTo("Teleport"); err == nil {
+		t.Fatal("expect unknown phase to be an error")
+	}
+}
+
+func TestHTTPStat_ReusedStart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	// With a single connection the second request waits for the first one
+	// to release it.
+	transport := DefaultTransport()
+	transport.MaxConnsPerHost = 1
+	client := &http.Client{Transport: transport}
+
+	get := func(path string, result *Result) {
+		req := NewRequest(t, srv.URL+path, result)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Error("client.Do failed:", err)
+			return
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		result.End(time.Now())
+	}
+
+	var first Result
+	get("/", &first)
+
+	done := make(chan struct{})
+	go func() {
+		var slow Result
+		get("/slow", &slow)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	var result Result
+	start := time.Now()
+	get("/", &result)
+	elapsed := time.Since(start)
+	<-done
+
+	if hook(result.hooks)&hookConnect != 0 {
+		t.Fatal("expect the connection to be reused")
+	}
+	if total := result.Total(time.Now()); total < elapsed-5*time.Millisecond {
+		t.Fatalf("Total is %s, want about %s", total, elapsed)
+	}
+}
+
+func TestPhaseNames(t *testing.T) {
+	names := PhaseNames()
+	want := []string{"DNSLookup", "TCPConnection", "TLSHandshake", "ServerProcessing", "ContentTransfer"}
+	if len(names) != len(want) {
+		t.Fatalf("PhaseNames is %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("PhaseNames is %v, want %v", names, want)
+		}
+	}
+
+	durations := (&Result{}).Durations()
+	if len(durations) != len(names) {
+		t.Fatalf("Durations has keys %v, want %v", durations, names)
+	}
+	for _, name := range names {
+		if _, ok := durations[name]; !ok {
+			t.Fatalf("Durations has no key %s", name)
+		}
+	}
+}
+
+func TestMarkBodyDone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	var result Result
+	res, err := DefaultClient().Do(NewRequest(t, ts.URL, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	before := time.Now()
+	result.MarkBodyDone()
+	after := time.Now()
+
+	if result.transferDone.Before(before) || result.transferDone.After(after) {
+		t.Fatalf("transfer done at %s, want between %s and %s", result.transferDone, before, after)
+	}
+	// Total is fixed by the mark, whatever time it's given later.
+	want := result.transferDone.Sub(result.start)
+	if got := result.Total(time.Now().Add(time.Hour)); got != want {
+		t.Fatalf("Total is %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package httpstattest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/georgeok/go-httpstat"
+)
+
+// TraceExpect sends a traced GET for url with client, reads the whole body
+// and fails t for every phase which took longer than its maximum in max.
+// The keys of max are the names of httpstat.PhaseNames or "Total", phases
+// without a maximum are not checked:
+//
+//	httpstattest.TraceExpect(t, client, srv.URL, map[string]time.Duration{
+//		"ServerProcessing": 100 * time.Millisecond,
+//		"Total":            time.Second,
+//	})
+//
+// A failed request fails t with Fatal.
+func TraceExpect(t testing.TB, client *http.Client, url string, max map[string]time.Duration) {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result httpstat.Result
+	ctx := httpstat.WithHTTPStat(context.Background(), &result)
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("request failed:", err)
+	}
+	_, err = io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal("reading the body failed:", err)
+	}
+	end := time.Now()
+	result.End(end)
+
+	durations := result.Durations()
+	durations["Total"] = result.Total(end)
+
+	// Report the phases in a stable order.
+	phases := make([]string, 0, len(max))
+	for phase := range max {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for _, phase := range phases {
+		d, ok := durations[phase]
+		if !ok {
+			t.Errorf("unknown phase %q", phase)
+			continue
+		}
+		if d > max[phase] {
+			t.Errorf("%s took %s, want at most %s", phase, d, max[phase])
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package httpstattest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// recorder is a testing.TB which records the failures instead of failing.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatal(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+	r.fatal = true
+	runtime.Goexit()
+}
+
+// expect runs TraceExpect with a recorder in its own goroutine, so that
+// Fatal can stop it like it stops a test.
+func expect(url string, max map[string]time.Duration) *recorder {
+	r := &recorder{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		TraceExpect(r, http.DefaultClient, url, max)
+	}()
+	<-done
+	return r
+}
+
+func TestTraceExpect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	TraceExpect(t, http.DefaultClient, srv.URL, map[string]time.Duration{
+		"ServerProcessing": 5 * time.Second,
+		"Total":            5 * time.Second,
+	})
+
+	r := expect(srv.URL, map[string]time.Duration{
+		"DNSLookup":        5 * time.Second,
+		"ServerProcessing": 10 * time.Millisecond,
+		"Total":            10 * time.Millisecond,
+		"Bogus":            time.Second,
+	})
+	if len(r.errors) != 3 || r.fatal {
+		t.Fatalf("got failures %q, want 3 errors", r.errors)
+	}
+	if want := `unknown phase "Bogus"`; r.errors[0] != want {
+		t.Fatalf("first failure is %q, want %q", r.errors[0], want)
+	}
+
+	r = expect("http://127.0.0.1:0/", nil)
+	if !r.fatal {
+		t.Fatalf("expect a failed request to be fatal, got %q", r.errors)
+	}
+}
@@ -0,0 +1,108 @@
+// Package httpstattest provides utilities for testing code which uses
+// httpstat: SimulateTrace records a trace without sending requests and
+// TraceExpect checks the latency of a real request.
+package httpstattest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/georgeok/go-httpstat"
+)
+
+// TraceEvent is a hook call of a scripted trace.
+type TraceEvent struct {
+	// Hook is the name of the httptrace.ClientTrace hook to call, like
+	// "DNSStart" or "GotFirstResponseByte", or "End" to call Result.End.
+	Hook string
+
+	// At is the offset of the call from the start of the script.
+	At time.Duration
+
+	// Reused is given to GotConn.
+	Reused bool
+
+	// Err is given to ConnectDone and TLSHandshakeDone.
+	Err error
+}
+
+// scriptStart is the time the offsets of a script are relative to.
+var scriptStart = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SimulateTrace records script on r as if the hooks were called by a real
+// request at the scripted times. Events must be in the order of their At.
+// The connection given to GotConn has the local address 192.0.2.1:50000 and
+// the remote one 192.0.2.2:443, and uses TLS when the script has a
+// TLSHandshakeStart.
+//
+// SimulateTrace panics on an unknown hook name.
+func SimulateTrace(r *httpstat.Result, script []TraceEvent) {
+	now := scriptStart
+	ctx := httpstat.WithHTTPStat(context.Background(), r, httpstat.WithClock(func() time.Time {
+		return now
+	}))
+	trace := httptrace.ContextClientTrace(ctx)
+
+	var conn net.Conn = fakeConn{}
+	for _, e := range script {
+		if e.Hook == "TLSHandshakeStart" {
+			conn = tls.Client(conn, &tls.Config{})
+		}
+	}
+
+	const host, addr = "example.com", "192.0.2.2:443"
+	for _, e := range script {
+		now = scriptStart.Add(e.At)
+		switch e.Hook {
+		case "GetConn":
+			trace.GetConn(host + ":443")
+		case "DNSStart":
+			trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+		case "DNSDone":
+			trace.DNSDone(httptrace.DNSDoneInfo{
+				Addrs: []net.IPAddr{{IP: net.ParseIP("192.0.2.2")}},
+				Err:   e.Err,
+			})
+		case "ConnectStart":
+			trace.ConnectStart("tcp", addr)
+		case "ConnectDone":
+			trace.ConnectDone("tcp", addr, e.Err)
+		case "TLSHandshakeStart":
+			trace.TLSHandshakeStart()
+		case "TLSHandshakeDone":
+			trace.TLSHandshakeDone(tls.ConnectionState{
+				Version:           tls.VersionTLS12,
+				HandshakeComplete: e.Err == nil,
+			}, e.Err)
+		case "GotConn":
+			trace.GotConn(httptrace.GotConnInfo{Conn: conn, Reused: e.Reused})
+		case "WroteHeaders":
+			trace.WroteHeaders()
+		case "WroteRequest":
+			trace.WroteRequest(httptrace.WroteRequestInfo{Err: e.Err})
+		case "GotFirstResponseByte":
+			trace.GotFirstResponseByte()
+		case "End":
+			r.End(now)
+		default:
+			panic(fmt.Sprintf("httpstattest: unknown hook %q", e.Hook))
+		}
+	}
+}
+
+// fakeConn is the connection given to GotConn. Only its addresses are used.
+type fakeConn struct {
+	net.Conn
+}
+
+func (fakeConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}
+}
+
+func (fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443}
+}
@@ -0,0 +1,58 @@
+package httpstattest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgeok/go-httpstat"
+)
+
+func TestSimulateTrace(t *testing.T) {
+	ms := time.Millisecond
+	script := []TraceEvent{
+		{Hook: "GetConn", At: 0},
+		{Hook: "DNSStart", At: 0},
+		{Hook: "DNSDone", At: 10 * ms},
+		{Hook: "ConnectStart", At: 10 * ms},
+		{Hook: "ConnectDone", At: 30 * ms},
+		{Hook: "TLSHandshakeStart", At: 30 * ms},
+		{Hook: "TLSHandshakeDone", At: 70 * ms},
+		{Hook: "GotConn", At: 70 * ms},
+		{Hook: "WroteHeaders", At: 71 * ms},
+		{Hook: "WroteRequest", At: 71 * ms},
+		{Hook: "GotFirstResponseByte", At: 120 * ms},
+		{Hook: "End", At: 150 * ms},
+	}
+
+	var result httpstat.Result
+	SimulateTrace(&result, script)
+
+	want := httpstat.Cumulative{
+		DNSLookup:   10 * ms,
+		TCPConnect:  30 * ms,
+		TLSComplete: 70 * ms,
+		FirstByte:   120 * ms,
+		Complete:    150 * ms,
+	}
+	if got := result.CumulativeOffsets(); got != want {
+		t.Fatalf("CumulativeOffsets is %+v, want %+v", got, want)
+	}
+	if !result.Complete() {
+		t.Fatal("expect scripted result to be complete")
+	}
+	if got := result.RemoteIP(); got != "192.0.2.2" {
+		t.Fatalf("RemoteIP is %q, want 192.0.2.2", got)
+	}
+	if !result.UsedDefaultPort() {
+		t.Fatal("expect TLS connection to 443 to use the default port")
+	}
+}
+
+func TestSimulateTrace_UnknownHook(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect unknown hook to panic")
+		}
+	}()
+	SimulateTrace(&httpstat.Result{}, []TraceEvent{{Hook: "Teleport"}})
+}
@@ -0,0 +1,30 @@
+// Package httpstatzap converts httpstat Results to uber-go/zap fields. It
+// lives in its own package so that httpstat itself doesn't depend on zap.
+//
+//	logger.Info("probe", httpstatzap.ZapFields(&result)...)
+package httpstatzap
+
+import (
+	"github.com/georgeok/go-httpstat"
+	"go.uber.org/zap"
+)
+
+// ZapFields returns the timeline of r as typed zap fields. The field names
+// are the same as the keys of the JSON encoding of Result.
+func ZapFields(r *httpstat.Result) []zap.Field {
+	c := r.CumulativeOffsets()
+	fields := []zap.Field{
+		zap.Duration("name_lookup", c.DNSLookup),
+		zap.Duration("connect", r.Connect),
+		zap.Duration("pre_transfer", c.TLSComplete),
+		zap.Duration("start_transfer", c.FirstByte),
+		zap.Duration("total", c.Complete),
+	}
+	if ip := r.LocalIp(); ip != "" {
+		fields = append(fields, zap.String("local_ip", ip))
+	}
+	if ip := r.RemoteIP(); ip != "" {
+		fields = append(fields, zap.String("remote_ip", ip))
+	}
+	return fields
+}
@@ -0,0 +1,45 @@
+package httpstatzap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgeok/go-httpstat"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapFields(t *testing.T) {
+	result := httpstat.Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       20 * time.Millisecond,
+		PreTransfer:   30 * time.Millisecond,
+		StartTransfer: 40 * time.Millisecond,
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	zap.New(core).Info("probe", ZapFields(&result)...)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d log entries, want 1", len(entries))
+	}
+
+	want := map[string]interface{}{
+		"name_lookup":    10 * time.Millisecond,
+		"connect":        20 * time.Millisecond,
+		"pre_transfer":   30 * time.Millisecond,
+		"start_transfer": 40 * time.Millisecond,
+		"total":          time.Duration(0),
+	}
+	got := entries[0].ContextMap()
+	if len(got) != len(want) {
+		t.Fatalf("got fields %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("field %s is %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package httpstat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewResult returns a Result with a random ID, see ID.
+func NewResult() *Result {
+	return &Result{id: newID()}
+}
+
+// NewContext returns a new Result with a random ID and ctx traced into it
+// with WithHTTPStat.
+func NewContext(ctx context.Context, opts ...Option) (context.Context, *Result) {
+	r := NewResult()
+	return WithHTTPStat(ctx, r, opts...), r
+}
+
+// newID returns 8 random bytes in hex, or "" when the system has no
+// randomness to give.
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// ID returns the correlation ID of the request, generated by NewResult and
+// NewContext unless set with SetID, to tie the Result to the logs of the
+// request. It is "" for a Result created otherwise and without SetID.
+func (r *Result) ID() string {
+	return r.id
+}
+
+// SetID sets the correlation ID returned by ID, for example to the request
+// ID already used by the logs.
+func (r *Result) SetID(id string) {
+	r.id = id
+}
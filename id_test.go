@@ -0,0 +1,67 @@
+package httpstat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestID(t *testing.T) {
+	a, b := NewResult(), NewResult()
+	if a.ID() == "" || len(a.ID()) != 16 {
+		t.Fatalf("ID is %q, want 16 hex characters", a.ID())
+	}
+	if a.ID() == b.ID() {
+		t.Fatalf("expect distinct IDs, both are %q", a.ID())
+	}
+
+	a.SetID("req-42")
+	if got := a.ID(); got != "req-42" {
+		t.Fatalf("ID is %q, want the one set", got)
+	}
+	if got := fmt.Sprintf("%+v", a); !strings.Contains(got, "ID:             req-42\n") {
+		t.Fatalf("expect verbose Format to show the ID:\n%s", got)
+	}
+	var v struct{ ID string }
+	if b, err := json.Marshal(a); err != nil || json.Unmarshal(b, &v) != nil || v.ID != "req-42" {
+		t.Fatalf("expect MarshalJSON to include the ID, got %q", v.ID)
+	}
+
+	if got := (&Result{}).ID(); got != "" {
+		t.Fatalf("ID of a Result not created by NewResult is %q, want \"\"", got)
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	ctx, result := NewContext(context.Background())
+	if result.ID() == "" {
+		t.Fatal("expect NewContext to generate an ID")
+	}
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	res, err := DefaultClient().Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+
+	if result.StartTransfer <= 0 {
+		t.Fatal("expect the request to be traced into the Result of NewContext")
+	}
+}
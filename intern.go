@@ -0,0 +1,38 @@
+package httpstat
+
+import "sync"
+
+// maxInterned bounds the number of interned addresses. Once the table is
+// full new addresses are not interned anymore.
+const maxInterned = 4096
+
+var interned = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// internAddr returns the interned copy of addr when WithAddrInterning is
+// set, addr otherwise.
+func (r *Result) internAddr(addr string) string {
+	if !r.cfg.internAddrs || addr == "" {
+		return addr
+	}
+	return intern(addr)
+}
+
+// intern returns the shared copy of s.
+func intern(s string) string {
+	interned.Lock()
+	defer interned.Unlock()
+	if v, ok := interned.m[s]; ok {
+		return v
+	}
+	if len(interned.m) >= maxInterned {
+		return s
+	}
+	// Copy s so that the table doesn't retain the larger string it may be
+	// a part of.
+	v := string([]byte(s))
+	interned.m[v] = v
+	return v
+}
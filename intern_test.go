@@ -0,0 +1,97 @@
+package httpstat
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"runtime"
+	"testing"
+)
+
+// addrConn is a net.Conn with fixed addresses.
+type addrConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c addrConn) LocalAddr() net.Addr  { return c.local }
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+// captureAddrs records a connection from 192.0.2.1 to 192.0.2.2 on a new
+// Result.
+func captureAddrs(opts ...Option) *Result {
+	conn := addrConn{
+		local:  &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000},
+		remote: &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443},
+	}
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result, opts...)
+	httptrace.ContextClientTrace(ctx).GotConn(httptrace.GotConnInfo{Conn: conn})
+	return &result
+}
+
+func TestWithAddrInterning(t *testing.T) {
+	// Start from an empty table so that it holds the addresses of this test
+	// only.
+	interned.Lock()
+	saved := interned.m
+	interned.m = make(map[string]string)
+	interned.Unlock()
+	defer func() {
+		interned.Lock()
+		interned.m = saved
+		interned.Unlock()
+	}()
+
+	captureAddrs()
+	if len(interned.m) != 0 {
+		t.Fatal("expect addresses not to be interned by default")
+	}
+
+	a, b := captureAddrs(WithAddrInterning()), captureAddrs(WithAddrInterning())
+	if a.RemoteIP() != "192.0.2.2" || a.LocalIp() != "192.0.2.1" {
+		t.Fatalf("addresses are %s and %s", a.LocalIp(), a.RemoteIP())
+	}
+	for _, addr := range []string{a.remoteAddr, a.localAddr} {
+		if _, ok := interned.m[addr]; !ok {
+			t.Fatalf("expect %s to be interned", addr)
+		}
+	}
+	// The second Result got the copies of the first one from the table
+	// instead of adding its own.
+	if len(interned.m) != 2 || b.remoteAddr != a.remoteAddr || b.localAddr != a.localAddr {
+		t.Fatalf("expect both Results to share 2 interned addresses, the table has %d", len(interned.m))
+	}
+}
+
+func BenchmarkAddrInterning(b *testing.B) {
+	// The addresses are formatted on every capture either way, so the saving
+	// shows in the memory retained by the Results and not in B/op.
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Default", nil},
+		{"Interning", []Option{WithAddrInterning()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			kept := make([]string, 0, 2*b.N)
+			before := heapInUse()
+			for i := 0; i < b.N; i++ {
+				r := captureAddrs(bc.opts...)
+				kept = append(kept, r.localAddr, r.remoteAddr)
+			}
+			b.ReportMetric(float64(heapInUse()-before)/float64(b.N), "retained-B/op")
+			runtime.KeepAlive(kept)
+		})
+	}
+}
+
+// heapInUse returns the live heap size after a garbage collection.
+func heapInUse() int64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
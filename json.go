@@ -0,0 +1,116 @@
+package httpstat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// resultJSON is the wire representation of Result. Durations are encoded as
+// nanoseconds, like time.Duration itself.
+type resultJSON struct {
+	ID            string        `json:"id,omitempty"`
+	NameLookup    time.Duration `json:"name_lookup"`
+	Connect       time.Duration `json:"connect"`
+	PreTransfer   time.Duration `json:"pre_transfer"`
+	StartTransfer time.Duration `json:"start_transfer"`
+	Total         time.Duration `json:"total"`
+	LocalIP       string        `json:"local_ip,omitempty"`
+	RemoteIP      string        `json:"remote_ip,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(resultJSON{
+		ID:            r.id,
+		NameLookup:    r.NameLookup,
+		Connect:       r.Connect,
+		PreTransfer:   r.PreTransfer,
+		StartTransfer: r.StartTransfer,
+		Total:         r.total,
+		LocalIP:       r.localAddr,
+		RemoteIP:      r.remoteAddr,
+	})
+}
+
+// WriteNDJSON writes results to w as newline delimited JSON, one object per
+// line. Each line can be parsed on its own.
+func WriteNDJSON(w io.Writer, results []*Result) error {
+	// json.Encoder terminates every value with a newline and never emits one
+	// inside a value, which is exactly what NDJSON requires.
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emfMetric is a metric definition of the CloudWatch Embedded Metric Format.
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// emfDirective is the CloudWatchMetrics directive of an EMF document.
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+// emfMetadata is the _aws block of an EMF document.
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// EMF returns r as a CloudWatch Embedded Metric Format document: a JSON
+// object with the duration of every phase and the total in milliseconds,
+// named like PhaseNames and "Total", the dimensions, and the _aws metadata
+// telling CloudWatch to extract them as metrics of namespace. Writing it to
+// the log of a Lambda function publishes the metrics. The timestamp is the
+// start of the request.
+//
+// It fails when a dimension has the name of a metric or of the _aws block.
+func (r *Result) EMF(namespace string, dimensions map[string]string) ([]byte, error) {
+	doc := make(map[string]interface{})
+	var metrics []emfMetric
+	for _, p := range r.phaseDurations() {
+		metrics = append(metrics, emfMetric{Name: p.name, Unit: "Milliseconds"})
+		doc[p.name] = float64(p.d) / float64(time.Millisecond)
+	}
+	metrics = append(metrics, emfMetric{Name: "Total", Unit: "Milliseconds"})
+	doc["Total"] = float64(r.total) / float64(time.Millisecond)
+
+	keys := make([]string, 0, len(dimensions))
+	for k, v := range dimensions {
+		if _, ok := doc[k]; ok || k == "_aws" {
+			return nil, fmt.Errorf("httpstat: dimension %q has a reserved name", k)
+		}
+		doc[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dims := [][]string{}
+	if len(keys) > 0 {
+		dims = append(dims, keys)
+	}
+
+	ts := r.start
+	if ts.IsZero() {
+		ts = r.now()
+	}
+	doc["_aws"] = emfMetadata{
+		Timestamp: ts.UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  namespace,
+			Dimensions: dims,
+			Metrics:    metrics,
+		}},
+	}
+	return json.Marshal(doc)
+}
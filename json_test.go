@@ -0,0 +1,132 @@
+package httpstat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteNDJSON(t *testing.T) {
+	results := []*Result{
+		{
+			NameLookup:    10 * time.Millisecond,
+			Connect:       20 * time.Millisecond,
+			PreTransfer:   30 * time.Millisecond,
+			StartTransfer: 40 * time.Millisecond,
+			total:         50 * time.Millisecond,
+			remoteAddr:    "192.0.2.1",
+		},
+		{
+			NameLookup:    1 * time.Millisecond,
+			Connect:       2 * time.Millisecond,
+			PreTransfer:   2 * time.Millisecond,
+			StartTransfer: 3 * time.Millisecond,
+			total:         4 * time.Millisecond,
+		},
+		{},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, results); err != nil {
+		t.Fatal("WriteNDJSON failed:", err)
+	}
+
+	var got []resultJSON
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var v resultJSON
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			t.Fatalf("line %d is not valid JSON: %s", len(got)+1, err)
+		}
+		got = append(got, v)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal("scan failed:", err)
+	}
+
+	if len(got) != len(results) {
+		t.Fatalf("got %d lines, want %d", len(got), len(results))
+	}
+	for i, r := range results {
+		want := resultJSON{
+			NameLookup:    r.NameLookup,
+			Connect:       r.Connect,
+			PreTransfer:   r.PreTransfer,
+			StartTransfer: r.StartTransfer,
+			Total:         r.total,
+			RemoteIP:      r.remoteAddr,
+		}
+		if got[i] != want {
+			t.Fatalf("line %d: got %+v, want %+v", i+1, got[i], want)
+		}
+	}
+}
+
+func TestEMF(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       30 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 160 * time.Millisecond,
+		total:         200500 * time.Microsecond,
+		start:         start,
+	}
+
+	b, err := result.EMF("Probes", map[string]string{"Endpoint": "api", "Region": "eu-west-1"})
+	if err != nil {
+		t.Fatal("EMF failed:", err)
+	}
+	var doc struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct{ Name, Unit string }
+			}
+		} `json:"_aws"`
+		Endpoint, Region string
+		DNSLookup        float64
+		ServerProcessing float64
+		Total            float64
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("EMF is not valid JSON: %s\n%s", err, b)
+	}
+
+	if doc.AWS.Timestamp != start.UnixNano()/int64(time.Millisecond) {
+		t.Fatalf("Timestamp is %d, want the start in milliseconds", doc.AWS.Timestamp)
+	}
+	if len(doc.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("got %d CloudWatchMetrics directives, want 1", len(doc.AWS.CloudWatchMetrics))
+	}
+	cwm := doc.AWS.CloudWatchMetrics[0]
+	if cwm.Namespace != "Probes" {
+		t.Fatalf("Namespace is %q, want Probes", cwm.Namespace)
+	}
+	if len(cwm.Dimensions) != 1 || len(cwm.Dimensions[0]) != 2 ||
+		cwm.Dimensions[0][0] != "Endpoint" || cwm.Dimensions[0][1] != "Region" {
+		t.Fatalf("Dimensions are %v, want [[Endpoint Region]]", cwm.Dimensions)
+	}
+	if len(cwm.Metrics) != len(PhaseNames())+1 {
+		t.Fatalf("got %d metrics, want one per phase and the total", len(cwm.Metrics))
+	}
+	for i, name := range append(PhaseNames(), "Total") {
+		if m := cwm.Metrics[i]; m.Name != name || m.Unit != "Milliseconds" {
+			t.Fatalf("metric %d is %+v, want %s in Milliseconds", i, m, name)
+		}
+	}
+	if doc.Endpoint != "api" || doc.Region != "eu-west-1" {
+		t.Fatalf("dimension values are %q and %q", doc.Endpoint, doc.Region)
+	}
+	if doc.DNSLookup != 10 || doc.ServerProcessing != 100 || doc.Total != 200.5 {
+		t.Fatalf("metric values are %v, %v and %v", doc.DNSLookup, doc.ServerProcessing, doc.Total)
+	}
+
+	if _, err := result.EMF("Probes", map[string]string{"Total": "x"}); err == nil {
+		t.Fatal("expect a dimension named like a metric to fail")
+	}
+}
@@ -0,0 +1,40 @@
+package httpstat
+
+import "time"
+
+// metricNames are the short names of the phases used for metrics.
+var metricNames = map[string]string{
+	phaseNames[phaseDNSLookup]:        "dns",
+	phaseNames[phaseTCPConnection]:    "tcp",
+	phaseNames[phaseTLSHandshake]:     "tls",
+	phaseNames[phaseServerProcessing]: "server",
+	phaseNames[phaseContentTransfer]:  "transfer",
+}
+
+// ObserveMetrics calls observe with the duration of every phase and the
+// total, named "dns", "tcp", "tls", "server", "transfer" and "total". It is
+// meant to feed metrics such as Prometheus histograms:
+//
+//	r.ObserveMetrics(func(name string, d time.Duration) {
+//		histograms[name].Observe(d.Seconds())
+//	})
+func (r *Result) ObserveMetrics(observe func(name string, d time.Duration)) {
+	for _, p := range r.phaseDurations() {
+		observe(metricNames[p.name], p.d)
+	}
+	observe("total", r.total)
+}
+
+// ObserveMetricsVec is like ObserveMetrics but gives the phase as a "phase"
+// label, so that a single metric vector holds all the phases:
+//
+//	r.ObserveMetricsVec(func(labels map[string]string, d time.Duration) {
+//		histogramVec.With(labels).Observe(d.Seconds())
+//	})
+//
+// The total is not observed as it is the sum of the phases.
+func (r *Result) ObserveMetricsVec(observe func(labels map[string]string, d time.Duration)) {
+	for _, p := range r.phaseDurations() {
+		observe(map[string]string{"phase": metricNames[p.name]}, p.d)
+	}
+}
@@ -0,0 +1,63 @@
+package httpstat
+
+import (
+	"testing"
+	"time"
+)
+
+var metricsResult = Result{
+	NameLookup:    10 * time.Millisecond,
+	Connect:       30 * time.Millisecond,
+	PreTransfer:   60 * time.Millisecond,
+	StartTransfer: 100 * time.Millisecond,
+	total:         150 * time.Millisecond,
+}
+
+func TestObserveMetrics(t *testing.T) {
+	got := map[string]time.Duration{}
+	metricsResult.ObserveMetrics(func(name string, d time.Duration) {
+		got[name] = d
+	})
+
+	want := map[string]time.Duration{
+		"dns":      10 * time.Millisecond,
+		"tcp":      20 * time.Millisecond,
+		"tls":      30 * time.Millisecond,
+		"server":   40 * time.Millisecond,
+		"transfer": 50 * time.Millisecond,
+		"total":    150 * time.Millisecond,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("observed %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s is %s, want %s", k, got[k], v)
+		}
+	}
+}
+
+func TestObserveMetricsVec(t *testing.T) {
+	var phases []string
+	var sum time.Duration
+	metricsResult.ObserveMetricsVec(func(labels map[string]string, d time.Duration) {
+		if len(labels) != 1 {
+			t.Fatalf("labels are %v, want only phase", labels)
+		}
+		phases = append(phases, labels["phase"])
+		sum += d
+	})
+
+	want := []string{"dns", "tcp", "tls", "server", "transfer"}
+	if len(phases) != len(want) {
+		t.Fatalf("phases are %v, want %v", phases, want)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Fatalf("phases are %v, want %v", phases, want)
+		}
+	}
+	if sum != metricsResult.total {
+		t.Fatalf("phases sum to %s, want %s", sum, metricsResult.total)
+	}
+}
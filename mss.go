@@ -0,0 +1,43 @@
+package httpstat
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrMSSUnsupported is returned by TCPMSS on platforms where the MSS of a
+// connection can't be read.
+var ErrMSSUnsupported = errors.New("httpstat: reading the MSS is not supported on this platform")
+
+// WithMSSProbe makes GotConn record the MSS (maximum segment size) of the
+// connection returned by probe in the MSS field of the Result. probe is
+// given the TCP connection, under the TLS one for HTTPS from go1.18 where
+// tls.Conn exposes it. TCPMSS is a probe for the platforms where the socket
+// exposes it. Errors of probe leave MSS at 0.
+//
+// A small MSS, for example behind a tunnel with a small path MTU, explains
+// large transfers being slower than the bandwidth allows.
+func WithMSSProbe(probe func(net.Conn) (int, error)) Option {
+	return func(c *config) {
+		c.mssProbe = probe
+	}
+}
+
+// recordMSS records the MSS of conn with the probe of WithMSSProbe.
+func (r *Result) recordMSS(conn net.Conn) {
+	probe := r.cfg.mssProbe
+	if probe == nil {
+		return
+	}
+	// Unwrap the TLS connection and the ones of ConnCounter.
+	for {
+		wrapped, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapped.NetConn()
+	}
+	if mss, err := probe(conn); err == nil {
+		r.MSS = mss
+	}
+}
@@ -0,0 +1,31 @@
+//go:build linux
+// +build linux
+
+package httpstat
+
+import (
+	"net"
+	"syscall"
+)
+
+// TCPMSS returns the MSS of conn, a TCP connection, read with the
+// TCP_MAXSEG socket option. It is meant to be given to WithMSSProbe.
+func TCPMSS(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, ErrMSSUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var mss int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		mss, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG)
+	}); err != nil {
+		return 0, err
+	}
+	return mss, sockErr
+}
@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package httpstat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTCPMSS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	result := doMSS(t, srv, TCPMSS)
+	if result.MSS <= 0 {
+		t.Fatalf("MSS is %d, want the one of the loopback connection", result.MSS)
+	}
+}
+
+func TestTCPMSS_ConnCounter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	// The probe sees the TCP connection under the one of the counter.
+	transport := DefaultTransport()
+	defer transport.CloseIdleConnections()
+	counter := NewConnCounter(transport)
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result, WithMSSProbe(TCPMSS), WithPoolStats(counter))
+	res, err := (&http.Client{Transport: transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if result.MSS <= 0 {
+		t.Fatalf("MSS is %d, want the one of the counted connection", result.MSS)
+	}
+}
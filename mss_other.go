@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package httpstat
+
+import "net"
+
+// TCPMSS returns the MSS of conn, a TCP connection. The platform doesn't
+// expose it, so it always returns ErrMSSUnsupported.
+func TCPMSS(conn net.Conn) (int, error) {
+	return 0, ErrMSSUnsupported
+}
@@ -0,0 +1,63 @@
+package httpstat
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// doMSS sends a traced GET to srv recording the MSS with probe. Every call
+// uses its own transport so that no dial of an earlier call is left running.
+func doMSS(t *testing.T, srv *httptest.Server, probe func(net.Conn) (int, error)) *Result {
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result, WithMSSProbe(probe))
+	transport := DefaultTransport()
+	transport.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	defer transport.CloseIdleConnections()
+	res, err := (&http.Client{Transport: transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	return &result
+}
+
+func TestWithMSSProbe(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var probed net.Conn
+	result := doMSS(t, srv, func(c net.Conn) (int, error) {
+		probed = c
+		return 1460, nil
+	})
+	if result.MSS != 1460 {
+		t.Fatalf("MSS is %d, want the one of the probe", result.MSS)
+	}
+	// tls.Conn exposes the TCP connection from go1.18.
+	if _, ok := interface{}(&tls.Conn{}).(interface{ NetConn() net.Conn }); ok {
+		if _, ok := probed.(*net.TCPConn); !ok {
+			t.Fatalf("probe was given a %T, want the TCP connection under TLS", probed)
+		}
+	}
+
+	failed := doMSS(t, srv, func(net.Conn) (int, error) {
+		return 1460, errors.New("not supported")
+	})
+	if failed.MSS != 0 {
+		t.Fatalf("MSS of a failed probe is %d, want 0", failed.MSS)
+	}
+}
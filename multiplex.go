@@ -0,0 +1,69 @@
+package httpstat
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StreamCollector collects the Results of requests multiplexed on shared
+// HTTP/2 connections to measure how much the streams of a connection delay
+// each other, see StreamContention. The zero value is ready to use and a
+// StreamCollector is safe for concurrent use.
+type StreamCollector struct {
+	mu    sync.Mutex
+	conns map[string][]stream // the streams by connection
+}
+
+// stream is when a request was sent and its first response byte came.
+type stream struct {
+	sent, firstByte time.Time
+}
+
+// Add records the stream of r, an ended Result. Results without a traced
+// connection or first response byte are ignored. For a redirected request
+// the first hop is recorded.
+func (c *StreamCollector) Add(r *Result) {
+	sent := r.timeline[milestoneWroteRequest]
+	firstByte := r.timeline[milestoneGotFirstResponseByte]
+	if r.connKey == "" || sent.IsZero() || firstByte.IsZero() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conns == nil {
+		c.conns = make(map[string][]stream)
+	}
+	c.conns[r.connKey] = append(c.conns[r.connKey], stream{sent: sent, firstByte: firstByte})
+}
+
+// StreamContention returns the mean time between the first response bytes
+// of successive streams which were in flight together on the same
+// connection, that is the next one was sent before the first byte of the
+// previous one came. Streams which don't compete get their first bytes at
+// about the same time; a large value means they were served one after the
+// other, for example because of head-of-line blocking or a server handling
+// the streams of a connection serially. It is 0 when no streams overlapped.
+func (c *StreamCollector) StreamContention() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var sum time.Duration
+	var n int
+	for _, streams := range c.conns {
+		sorted := append([]stream(nil), streams...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].firstByte.Before(sorted[j].firstByte) })
+		for i := 1; i < len(sorted); i++ {
+			prev, cur := sorted[i-1], sorted[i]
+			if cur.sent.Before(prev.firstByte) {
+				sum += cur.firstByte.Sub(prev.firstByte)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / time.Duration(n)
+}
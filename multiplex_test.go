@@ -0,0 +1,76 @@
+package httpstat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamContention(t *testing.T) {
+	// The server handles one stream at a time, so concurrent streams wait
+	// for each other.
+	var serial sync.Mutex
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serial.Lock()
+		time.Sleep(30 * time.Millisecond)
+		serial.Unlock()
+		io.WriteString(w, "ok")
+	}))
+	StartHTTP2(srv)
+	defer srv.Close()
+
+	client := srv.Client()
+	get := func() *Result {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Error("NewRequest failed:", err)
+			return nil
+		}
+		var result Result
+		res, err := client.Do(req.WithContext(WithHTTPStat(context.Background(), &result)))
+		if err != nil {
+			t.Error("client.Do failed:", err)
+			return nil
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		result.End(time.Now())
+		if res.ProtoMajor != 2 {
+			t.Errorf("got %s response, want HTTP/2", res.Proto)
+		}
+		return &result
+	}
+
+	// Requests one after the other don't compete.
+	var sequential StreamCollector
+	for i := 0; i < 3; i++ {
+		if r := get(); r != nil {
+			sequential.Add(r)
+		}
+	}
+	if got := sequential.StreamContention(); got != 0 {
+		t.Fatalf("StreamContention of sequential requests is %s, want 0", got)
+	}
+
+	// The connection is established, the concurrent requests share it.
+	var concurrent StreamCollector
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r := get(); r != nil {
+				concurrent.Add(r)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := concurrent.StreamContention(); got < 20*time.Millisecond {
+		t.Fatalf("StreamContention of competing streams is %s, want about 30ms", got)
+	}
+}
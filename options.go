@@ -0,0 +1,251 @@
+package httpstat
+
+import (
+	"net"
+	"net/textproto"
+	"time"
+)
+
+const defaultRateSampleInterval = 100 * time.Millisecond
+
+// Default thresholds of Severity.
+const (
+	defaultWarnThreshold     = 500 * time.Millisecond
+	defaultCriticalThreshold = 2 * time.Second
+)
+
+// defaultWarningThresholds are the phase durations from which Warnings
+// reports a phase.
+var defaultWarningThresholds = map[string]time.Duration{
+	"DNSLookup":        time.Second,
+	"TCPConnection":    time.Second,
+	"TLSHandshake":     500 * time.Millisecond,
+	"ServerProcessing": 2 * time.Second,
+}
+
+// Option configures how WithHTTPStat records a Result.
+type Option func(*config)
+
+type config struct {
+	// curlConnect makes Connect exclude the name lookup.
+	curlConnect bool
+
+	// rateSampleInterval is the minimum time between rate samples. Zero
+	// means defaultRateSampleInterval.
+	rateSampleInterval time.Duration
+
+	// rounding is the unit End rounds the durations to.
+	rounding time.Duration
+
+	// tlsMin and tlsMax are the TLS versions offered by the client.
+	tlsMin, tlsMax uint16
+
+	// nextProtos are the ALPN protocols offered by the client, in order
+	// of preference.
+	nextProtos []string
+
+	// warnThreshold and criticalThreshold are the totals from which
+	// Severity is "warn" and "critical". Zero means the defaults.
+	warnThreshold, criticalThreshold time.Duration
+
+	// warningThresholds override defaultWarningThresholds by phase name.
+	warningThresholds map[string]time.Duration
+
+	// plainLabels makes StatusLabel leave out the emoji.
+	plainLabels bool
+
+	// formatWidth is the minimum width of the values of Format.
+	formatWidth int
+
+	// captureHeaders are the canonical names of the response headers
+	// SetResponse captures.
+	captureHeaders []string
+
+	// captureDNSServer makes the resolver of WrapResolver record the
+	// address of the DNS server.
+	captureDNSServer bool
+
+	// socksProxy is the address of the SOCKS proxy, see WithSOCKSProxy.
+	socksProxy string
+
+	// bodyPeek is the number of body bytes WrapBody keeps, see
+	// WithBodyPeek.
+	bodyPeek int
+
+	// connCounter counts the connections of the transport, see
+	// WithPoolStats.
+	connCounter *ConnCounter
+
+	// connectProxy is the address of the HTTP proxy, see
+	// WithConnectProxy.
+	connectProxy string
+
+	// preferredSource is the local IP the connection is expected to use,
+	// see WithPreferredSource.
+	preferredSource net.IP
+
+	// mssProbe reads the MSS of the connection, see WithMSSProbe.
+	mssProbe func(net.Conn) (int, error)
+
+	// traceLog makes the hooks record their calls, see WithTraceLog.
+	traceLog bool
+
+	// internAddrs makes the captured addresses share storage.
+	internAddrs bool
+
+	// clock replaces time.Now when set.
+	clock func() time.Time
+
+	// User callbacks, see callbacks.go.
+	onPhase    func(phase string, d time.Duration)
+	onConnect  func(network, addr string, err error)
+	onPanic    func(v interface{})
+	onPanicSet bool
+}
+
+// WithCurlConnectSemantics makes Connect measure only the TCP handshake
+// (from ConnectStart to ConnectDone) instead of the time from the start of
+// the name lookup, for users comparing with tools which report the TCP
+// connect on its own. NameLookup and the other fields are not changed.
+func WithCurlConnectSemantics() Option {
+	return func(c *config) {
+		c.curlConnect = true
+	}
+}
+
+// WithRateSampleInterval sets the minimum time between the samples returned
+// by RateSamples, which bounds their memory use on long downloads. The
+// default is 100ms.
+func WithRateSampleInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.rateSampleInterval = d
+	}
+}
+
+// WithNextProtos tells the Result which ALPN protocols the client offers in
+// order of preference, usually the NextProtos of its tls.Config, like "h2"
+// and "http/1.1" for a transport attempting HTTP/2. ALPNMatched compares the
+// negotiated protocol with them.
+func WithNextProtos(protos ...string) Option {
+	return func(c *config) {
+		c.nextProtos = protos
+	}
+}
+
+// WithRounding makes End round NameLookup, Connect, PreTransfer,
+// StartTransfer and the total to a multiple of unit, so that consumers
+// don't see nanosecond noise. By default durations are not rounded.
+func WithRounding(unit time.Duration) Option {
+	return func(c *config) {
+		c.rounding = unit
+	}
+}
+
+// WithTLSVersions tells the Result which TLS versions the client offers,
+// usually the MinVersion and MaxVersion of its tls.Config. TLSDowngraded
+// compares the negotiated version with them.
+func WithTLSVersions(min, max uint16) Option {
+	return func(c *config) {
+		c.tlsMin, c.tlsMax = min, max
+	}
+}
+
+// WithFormatWidth sets the minimum width of the millisecond values printed by
+// Format. Values which don't fit widen all of them so they stay aligned.
+func WithFormatWidth(width int) Option {
+	return func(c *config) {
+		c.formatWidth = width
+	}
+}
+
+// WithClock makes the Result read the time from now instead of time.Now,
+// which lets tests simulate a trace with chosen timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.clock = now
+	}
+}
+
+// now returns the current time of the clock of r.
+func (r *Result) now() time.Time {
+	if r.cfg.clock != nil {
+		return r.cfg.clock()
+	}
+	return time.Now()
+}
+
+// WithCaptureHeaders makes SetResponse store the given response headers in
+// CapturedHeaders, for example "Server", "Via" and "X-Cache" for CDN
+// diagnostics, without keeping the whole response.
+func WithCaptureHeaders(names ...string) Option {
+	return func(c *config) {
+		for _, name := range names {
+			c.captureHeaders = append(c.captureHeaders, textproto.CanonicalMIMEHeaderKey(name))
+		}
+	}
+}
+
+// WithCaptureDNSServer records the address of the DNS server which answered
+// the name lookup, see DNSServer. It needs the resolver returned by
+// WrapResolver to be set on the Dialer of the transport.
+func WithCaptureDNSServer() Option {
+	return func(c *config) {
+		c.captureDNSServer = true
+	}
+}
+
+// WithPreferredSource sets the local IP the connection is expected to use,
+// for example the one a multi-homed host should route the request from, see
+// MatchedPreferredSource. It doesn't change which IP the dialer binds to.
+func WithPreferredSource(ip string) Option {
+	return func(c *config) {
+		c.preferredSource = net.ParseIP(ip)
+	}
+}
+
+// WithAddrInterning makes Results share the storage of identical local and
+// remote IPs, which saves memory when many Results are kept for the same
+// few hosts. The strings are kept in a bounded package level table.
+func WithAddrInterning() Option {
+	return func(c *config) {
+		c.internAddrs = true
+	}
+}
+
+// WithSeverityThresholds sets the totals from which Severity reports "warn"
+// and "critical" instead of the defaults of 500ms and 2s.
+func WithSeverityThresholds(warn, critical time.Duration) Option {
+	return func(c *config) {
+		c.warnThreshold = warn
+		c.criticalThreshold = critical
+	}
+}
+
+// WithPlainStatusLabels makes StatusLabel return its labels without the
+// emoji, for terminals and logs which can't show them.
+func WithPlainStatusLabels() Option {
+	return func(c *config) {
+		c.plainLabels = true
+	}
+}
+
+// WithWarningThreshold sets the duration from which Warnings reports the
+// phase named phase, one of PhaseNames. A zero or negative d turns the
+// warning off. The defaults are 1s for DNSLookup and TCPConnection, 500ms
+// for TLSHandshake and 2s for ServerProcessing.
+func WithWarningThreshold(phase string, d time.Duration) Option {
+	return func(c *config) {
+		if c.warningThresholds == nil {
+			c.warningThresholds = make(map[string]time.Duration)
+		}
+		c.warningThresholds[phase] = d
+	}
+}
+
+// WithBodyPeek makes the body wrapped by WrapBody keep a copy of its first n
+// bytes, see BodyPeek. The caller still reads the whole body.
+func WithBodyPeek(n int) Option {
+	return func(c *config) {
+		c.bodyPeek = n
+	}
+}
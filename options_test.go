@@ -0,0 +1,74 @@
+package httpstat
+
+import (
+	"context"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+// traceConnect simulates a lookup taking dns followed by a TCP handshake taking
+// tcp on a Result recorded with opts.
+func traceConnect(dns, tcp time.Duration, opts ...Option) *Result {
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result, opts...)
+	trace := httptrace.ContextClientTrace(ctx)
+
+	trace.DNSStart(httptrace.DNSStartInfo{Host: "example.com"})
+	time.Sleep(dns)
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.ConnectStart("tcp", "192.0.2.1:80")
+	time.Sleep(tcp)
+	trace.ConnectDone("tcp", "192.0.2.1:80", nil)
+	return &result
+}
+
+func TestWithCurlConnectSemantics(t *testing.T) {
+	dns, tcp := 50*time.Millisecond, 20*time.Millisecond
+
+	result := traceConnect(dns, tcp, WithCurlConnectSemantics())
+	if result.Connect < tcp || result.Connect >= dns {
+		t.Fatalf("Connect is %s, want TCP handshake (%s) only", result.Connect, tcp)
+	}
+	if got := result.CumulativeOffsets().TCPConnect; got < dns+tcp {
+		t.Fatalf("TCPConnect offset is %s, want at least %s", got, dns+tcp)
+	}
+
+	// The default still includes the name lookup.
+	result = traceConnect(dns, tcp)
+	if result.Connect < dns+tcp {
+		t.Fatalf("Connect is %s, want at least %s", result.Connect, dns+tcp)
+	}
+}
+
+func TestWithRounding(t *testing.T) {
+	ms := time.Millisecond
+	result := Result{
+		NameLookup:    12*ms + 345*time.Microsecond,
+		Connect:       20*ms + 501*time.Microsecond,
+		PreTransfer:   30*ms + 499*time.Microsecond,
+		StartTransfer: 40*ms + 900*time.Microsecond,
+	}
+	WithHTTPStat(context.Background(), &result, WithRounding(ms))
+	result.start = time.Now()
+	result.End(result.start.Add(50*ms + 200*time.Microsecond))
+
+	for _, d := range []time.Duration{
+		result.NameLookup, result.Connect, result.PreTransfer,
+		result.StartTransfer, result.total,
+	} {
+		if d%ms != 0 {
+			t.Fatalf("expect %s to be rounded to %s", d, ms)
+		}
+	}
+	want := Cumulative{
+		DNSLookup:   12 * ms,
+		TCPConnect:  21 * ms,
+		TLSComplete: 30 * ms,
+		FirstByte:   41 * ms,
+		Complete:    50 * ms,
+	}
+	if got := result.CumulativeOffsets(); got != want {
+		t.Fatalf("CumulativeOffsets is %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package httpstat
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// PoolStats is the state of the connection pool of a transport when a
+// request got its connection.
+type PoolStats struct {
+	// Open is the number of connections the transport has open, in use
+	// or idle, to all hosts.
+	Open int
+	// OpenForHost is the number of connections open to the host of the
+	// request, including the one it got.
+	OpenForHost int
+	// MaxConnsPerHost is the limit of connections per host of the
+	// transport, 0 for no limit.
+	MaxConnsPerHost int
+}
+
+// Saturated reports whether the host had as many connections open as the
+// transport allows, so a request needing a new connection had to wait for
+// one to be released.
+func (s PoolStats) Saturated() bool {
+	return s.MaxConnsPerHost > 0 && s.OpenForHost >= s.MaxConnsPerHost
+}
+
+// ConnCounter counts the connections a transport has open, for
+// WithPoolStats. http.Transport doesn't tell the state of its pool, so the
+// counter wraps the dialer of the transport to see the connections being
+// opened and closed.
+type ConnCounter struct {
+	mu              sync.Mutex
+	open            map[string]int // by host:port
+	maxConnsPerHost int
+}
+
+// NewConnCounter returns a counter of the connections of t. It replaces
+// t.DialContext with one that counts the connections, so it must be called
+// before t is used. Connections made by t.DialTLSContext or t.DialTLS are
+// not counted.
+func NewConnCounter(t *http.Transport) *ConnCounter {
+	c := &ConnCounter{open: make(map[string]int), maxConnsPerHost: t.MaxConnsPerHost}
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.open[addr]++
+		c.mu.Unlock()
+		return &countedConn{Conn: conn, c: c, addr: addr}, nil
+	}
+	return c
+}
+
+// Stats returns the state of the pool for requests to hostPort.
+func (c *ConnCounter) Stats(hostPort string) PoolStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := PoolStats{OpenForHost: c.open[hostPort], MaxConnsPerHost: c.maxConnsPerHost}
+	for _, n := range c.open {
+		s.Open += n
+	}
+	return s
+}
+
+// countedConn is a connection counted by a ConnCounter until it is closed.
+type countedConn struct {
+	net.Conn
+	c    *ConnCounter
+	addr string
+	once sync.Once
+}
+
+func (cc *countedConn) Close() error {
+	cc.once.Do(func() {
+		cc.c.mu.Lock()
+		if cc.c.open[cc.addr]--; cc.c.open[cc.addr] <= 0 {
+			delete(cc.c.open, cc.addr)
+		}
+		cc.c.mu.Unlock()
+	})
+	return cc.Conn.Close()
+}
+
+// NetConn returns the connection which was dialed, like tls.Conn.NetConn,
+// for the probe of WithMSSProbe to see the TCP connection.
+func (cc *countedConn) NetConn() net.Conn {
+	return cc.Conn
+}
+
+// WithPoolStats records the state of the connection pool counted by c when
+// the request gets its connection, see PoolStats. Slow requests on a
+// saturated pool waited for a connection rather than for the network.
+func WithPoolStats(c *ConnCounter) Option {
+	return func(cfg *config) {
+		cfg.connCounter = c
+	}
+}
+
+// PoolStats returns the state of the connection pool when the request got
+// its connection. It needs WithPoolStats and is the zero PoolStats until
+// GotConn.
+func (r *Result) PoolStats() PoolStats {
+	return r.poolStats
+}
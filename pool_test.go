@@ -0,0 +1,68 @@
+package httpstat
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithPoolStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	hostPort := strings.TrimPrefix(srv.URL, "http://")
+
+	transport := DefaultTransport()
+	transport.MaxConnsPerHost = 1
+	counter := NewConnCounter(transport)
+	client := &http.Client{Transport: transport}
+
+	// Two concurrent requests share the only connection allowed: the second
+	// one waits for the first one to release it.
+	results := make([]*Result, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = &Result{}
+		wg.Add(1)
+		go func(r *Result) {
+			defer wg.Done()
+			req, err := http.NewRequest("GET", srv.URL, nil)
+			if err != nil {
+				t.Error("NewRequest failed:", err)
+				return
+			}
+			res, err := client.Do(req.WithContext(WithHTTPStat(req.Context(), r, WithPoolStats(counter))))
+			if err != nil {
+				t.Error("client.Do failed:", err)
+				return
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+			r.End(time.Now())
+		}(results[i])
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		got := r.PoolStats()
+		want := PoolStats{Open: 1, OpenForHost: 1, MaxConnsPerHost: 1}
+		if got != want {
+			t.Fatalf("PoolStats of request %d is %+v, want %+v", i+1, got, want)
+		}
+		if !got.Saturated() {
+			t.Fatalf("expect the pool of request %d to be saturated", i+1)
+		}
+	}
+
+	transport.CloseIdleConnections()
+	if got := counter.Stats(hostPort); got.Open != 0 || got.OpenForHost != 0 {
+		t.Fatalf("Stats after closing the connections is %+v, want none open", got)
+	}
+}
@@ -0,0 +1,66 @@
+package httpstat
+
+import "time"
+
+// WithSOCKSProxy tells the address (ip:port) of the SOCKS5 proxy the
+// transport dials through, for example with
+//
+//	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "socks5", Host: addr})
+//
+// httptrace reports the TCP connection to the proxy like one to the origin.
+// When a connection is made to addr the Result records ViaSOCKS and the
+// time to connect to the proxy in ProxyConnect. Connect and the phases
+// after it then include the SOCKS handshake, in which the proxy connects to
+// the origin. HTTP proxies tunnelling with CONNECT are not recorded.
+func WithSOCKSProxy(addr string) Option {
+	return func(c *config) {
+		c.socksProxy = addr
+	}
+}
+
+// socksConnected records a successful connection to the SOCKS proxy, which
+// took d to connect to addr.
+func (r *Result) socksConnected(addr string, d time.Duration, err error) {
+	if r.cfg.socksProxy == "" || addr != r.cfg.socksProxy || err != nil {
+		return
+	}
+	r.ViaSOCKS = true
+	r.ProxyConnect += d
+}
+
+// WithConnectProxy tells the address (ip:port) of the HTTP proxy the
+// transport sends its requests through, for example with
+//
+//	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: addr})
+//
+// For an https URL the transport connects to the proxy, asks it with
+// CONNECT for a tunnel to the origin and then makes the TLS handshake with
+// the origin through the tunnel. When a connection is made to addr and a
+// TLS handshake follows the Result records ViaConnectTunnel and the time
+// the tunnel took to be established in TunnelSetup, which TLSHandshake
+// would otherwise not tell apart from the TCP connection. Proxies reached
+// over TLS (an https proxy URL) are not supported.
+func WithConnectProxy(addr string) Option {
+	return func(c *config) {
+		c.connectProxy = addr
+	}
+}
+
+// proxyConnectedAt records a successful connection to the CONNECT proxy at
+// addr at t.
+func (r *Result) proxyConnectedAt(addr string, t time.Time, err error) {
+	if r.cfg.connectProxy == "" || addr != r.cfg.connectProxy || err != nil {
+		return
+	}
+	r.proxyConnected = t
+}
+
+// tunnelEstablished records that the TLS handshake started at t, which for
+// a connection to the CONNECT proxy means the tunnel was established.
+func (r *Result) tunnelEstablished(t time.Time) {
+	if r.proxyConnected.IsZero() || r.ViaConnectTunnel {
+		return
+	}
+	r.ViaConnectTunnel = true
+	r.TunnelSetup = t.Sub(r.proxyConnected)
+}
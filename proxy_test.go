@@ -0,0 +1,195 @@
+package httpstat
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// socksServer starts a SOCKS5 proxy without authentication which only
+// supports CONNECT to an IPv4 address or a domain name.
+func socksServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen failed:", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS(conn)
+		}
+	}()
+	return ln
+}
+
+func serveSOCKS(conn net.Conn) {
+	defer conn.Close()
+
+	// Greeting: version, number of methods, methods.
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	// Request: version, command, reserved, address type, address, port.
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	origin, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer origin.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(origin, conn)
+	io.Copy(conn, origin)
+}
+
+func TestWithSOCKSProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	proxy := socksServer(t)
+	defer proxy.Close()
+	proxyAddr := proxy.Addr().String()
+
+	transport := DefaultTransport()
+	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "socks5", Host: proxyAddr})
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	res, err := client.Do(req.WithContext(WithHTTPStat(req.Context(), &result, WithSOCKSProxy(proxyAddr))))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status is %d, want 200", res.StatusCode)
+	}
+	if !result.ViaSOCKS {
+		t.Fatal("expect the connection to be via SOCKS")
+	}
+	if result.ProxyConnect <= 0 || result.ProxyConnect > result.Connect {
+		t.Fatalf("ProxyConnect is %s, want more than 0 and at most Connect %s", result.ProxyConnect, result.Connect)
+	}
+
+	// A direct connection isn't flagged.
+	direct := do(t, DefaultClient(), srv.URL)
+	if direct.ViaSOCKS {
+		t.Fatal("expect a direct connection not to be via SOCKS")
+	}
+}
+
+// connectProxy starts an HTTP proxy which only tunnels with CONNECT, after
+// waiting for delay.
+func connectProxy(t *testing.T, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		origin, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer origin.Close()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		time.Sleep(delay)
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(origin, conn)
+		io.Copy(conn, origin)
+	}))
+}
+
+func TestWithConnectProxy(t *testing.T) {
+	srv := tlsServer(tls.VersionTLS13)
+	defer srv.Close()
+	proxy := connectProxy(t, 30*time.Millisecond)
+	defer proxy.Close()
+	proxyAddr := proxy.Listener.Addr().String()
+
+	transport := DefaultTransport()
+	transport.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: proxyAddr})
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	res, err := client.Do(req.WithContext(WithHTTPStat(req.Context(), &result, WithConnectProxy(proxyAddr))))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+
+	if !result.ViaConnectTunnel {
+		t.Fatal("expect the connection to be tunnelled")
+	}
+	if result.TunnelSetup < 30*time.Millisecond {
+		t.Fatalf("TunnelSetup is %s, want at least the 30ms delay of the proxy", result.TunnelSetup)
+	}
+
+	// A direct TLS connection isn't tunnelled.
+	if direct := doTLS(t, srv, WithConnectProxy(proxyAddr)); direct.ViaConnectTunnel {
+		t.Fatal("expect a direct connection not to be tunnelled")
+	}
+}
@@ -0,0 +1,75 @@
+package httpstat
+
+import "time"
+
+// Recorder keeps the durations of the phases of the last Results recorded,
+// in fixed size ring buffers, for offline analysis like flame graphs or
+// heatmaps of many requests in bounded memory. Unlike Window it keeps only
+// the durations, not the Results. The phase names are the ones of
+// PhaseNames, or "Total".
+//
+// A Recorder is not safe for concurrent use.
+type Recorder struct {
+	rings map[string]*ring
+}
+
+// NewRecorder returns a Recorder of the last size Results. size less than 1
+// is treated as 1.
+func NewRecorder(size int) *Recorder {
+	if size < 1 {
+		size = 1
+	}
+	rings := make(map[string]*ring)
+	for _, name := range PhaseNames() {
+		rings[name] = &ring{buf: make([]time.Duration, 0, size)}
+	}
+	rings["Total"] = &ring{buf: make([]time.Duration, 0, size)}
+	return &Recorder{rings: rings}
+}
+
+// Record adds the durations of r, overwriting the oldest ones when the
+// Recorder is full. r is not retained.
+func (rec *Recorder) Record(r *Result) {
+	for _, p := range r.phaseDurations() {
+		rec.rings[p.name].add(p.d)
+	}
+	rec.rings["Total"].add(r.total)
+}
+
+// Len returns the number of Results whose durations are kept, at most the
+// size given to NewRecorder.
+func (rec *Recorder) Len() int {
+	return len(rec.rings["Total"].buf)
+}
+
+// Distribution returns the durations kept for every phase and the total,
+// oldest first. The slices are copies.
+func (rec *Recorder) Distribution() map[string][]time.Duration {
+	dist := make(map[string][]time.Duration, len(rec.rings))
+	for name, r := range rec.rings {
+		dist[name] = r.values()
+	}
+	return dist
+}
+
+// ring is a ring buffer of durations.
+type ring struct {
+	buf  []time.Duration
+	next int // index of the oldest value once the ring is full
+}
+
+func (r *ring) add(d time.Duration) {
+	if len(r.buf) < cap(r.buf) {
+		r.buf = append(r.buf, d)
+		return
+	}
+	r.buf[r.next] = d
+	r.next = (r.next + 1) % len(r.buf)
+}
+
+// values returns a copy of the values, oldest first.
+func (r *ring) values() []time.Duration {
+	values := make([]time.Duration, 0, len(r.buf))
+	values = append(values, r.buf[r.next:]...)
+	return append(values, r.buf[:r.next]...)
+}
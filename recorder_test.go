@@ -0,0 +1,49 @@
+package httpstat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder(3)
+	for _, ms := range []int{1000, 2000, 10, 20, 30} {
+		rec.Record(&Result{
+			StartTransfer: time.Duration(ms) * time.Millisecond,
+			total:         time.Duration(ms+1) * time.Millisecond,
+		})
+	}
+
+	if got := rec.Len(); got != 3 {
+		t.Fatalf("Len is %d, want 3", got)
+	}
+	dist := rec.Distribution()
+	if len(dist) != len(PhaseNames())+1 {
+		t.Fatalf("Distribution has %d phases, want %d", len(dist), len(PhaseNames())+1)
+	}
+
+	// 1000ms and 2000ms were overwritten.
+	ms := time.Millisecond
+	for phase, want := range map[string][]time.Duration{
+		"ServerProcessing": {10 * ms, 20 * ms, 30 * ms},
+		"ContentTransfer":  {ms, ms, ms},
+		"Total":            {11 * ms, 21 * ms, 31 * ms},
+		"DNSLookup":        {0, 0, 0},
+	} {
+		got := dist[phase]
+		if len(got) != len(want) {
+			t.Fatalf("%s is %v, want %v", phase, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s is %v, want %v", phase, got, want)
+			}
+		}
+	}
+
+	// The distribution is a copy.
+	dist["Total"][0] = time.Hour
+	if got := rec.Distribution()["Total"][0]; got != 11*ms {
+		t.Fatalf("oldest total is %s after changing the copy, want 11ms", got)
+	}
+}
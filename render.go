@@ -0,0 +1,299 @@
+package httpstat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// phaseLabels are the human readable names of the phases.
+var phaseLabels = map[string]string{
+	phaseNames[phaseDNSLookup]:        "DNS Lookup",
+	phaseNames[phaseTCPConnection]:    "TCP Connection",
+	phaseNames[phaseTLSHandshake]:     "TLS Handshake",
+	phaseNames[phaseServerProcessing]: "Server Processing",
+	phaseNames[phaseContentTransfer]:  "Content Transfer",
+}
+
+// FixedScaleBars writes one bar per phase where each character stands for
+// msPerChar milliseconds. Unlike a proportional chart the scale doesn't
+// depend on the Result, so bars of different Results printed one after
+// another can be compared directly. msPerChar less than 1 is treated as 1.
+// The values are 4 wide, widened to fit the largest one so that the bars
+// start in the same column.
+func (r *Result) FixedScaleBars(w io.Writer, msPerChar int) {
+	if msPerChar < 1 {
+		msPerChar = 1
+	}
+	phases := r.phaseDurations()
+	width := 4
+	for _, p := range phases {
+		if n := len(strconv.Itoa(int(p.d / time.Millisecond))); n > width {
+			width = n
+		}
+	}
+	for _, p := range phases {
+		ms := int(p.d / time.Millisecond)
+		fmt.Fprintf(w, "%-18s %*d ms |%s\n",
+			phaseLabels[p.name]+":", width, ms, strings.Repeat("#", ms/msPerChar))
+	}
+}
+
+// FormatTree writes the cumulative durations as the tree of the doc comment
+// of Result, each level containing the ones above it:
+//
+//	|
+//	|--NameLookup                12 ms
+//	|--|--Connect                40 ms
+//	|--|--|--PreTransfer         95 ms
+//	|--|--|--|--StartTransfer  1300 ms
+//	|--|--|--|--|--Total       1450 ms
+func (r *Result) FormatTree(w io.Writer) {
+	levels := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"NameLookup", r.NameLookup},
+		{"Connect", r.Connect},
+		{"PreTransfer", r.PreTransfer},
+		{"StartTransfer", r.StartTransfer},
+		{"Total", r.total},
+	}
+
+	labels := make([]string, len(levels))
+	values := make([]string, len(levels))
+	lw, vw := 0, 0
+	for i, l := range levels {
+		labels[i] = strings.Repeat("|--", i+1) + l.name
+		values[i] = strconv.Itoa(int(l.d / time.Millisecond))
+		if len(labels[i]) > lw {
+			lw = len(labels[i])
+		}
+		if len(values[i]) > vw {
+			vw = len(values[i])
+		}
+	}
+
+	io.WriteString(w, "|\n")
+	for i := range levels {
+		fmt.Fprintf(w, "%-*s  %*s ms\n", lw, labels[i], vw, values[i])
+	}
+}
+
+// phaseColors are the fill colors of the phases in SVGTimeline.
+var phaseColors = map[string]string{
+	phaseNames[phaseDNSLookup]:        "#4e79a7",
+	phaseNames[phaseTCPConnection]:    "#f28e2b",
+	phaseNames[phaseTLSHandshake]:     "#e15759",
+	phaseNames[phaseServerProcessing]: "#76b7b2",
+	phaseNames[phaseContentTransfer]:  "#59a14f",
+}
+
+// SVGTimeline writes the phases as an SVG image of width by height pixels:
+// a horizontal bar with one colored rectangle per phase, as wide as its
+// share of the total and titled with its name and duration, and the labels
+// of the phases under it. Phases which took no time are left out. Until End
+// is called the image only says that there is no data.
+func (r *Result) SVGTimeline(w io.Writer, width, height int) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	defer io.WriteString(w, "</svg>\n")
+
+	if r.total <= 0 {
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">no data</text>`+"\n", width/2, height/2)
+		return
+	}
+
+	barHeight := height * 2 / 3
+	var x float64
+	for _, p := range r.phaseDurations() {
+		if p.d <= 0 {
+			continue
+		}
+		pw := float64(width) * float64(p.d) / float64(r.total)
+		label := fmt.Sprintf("%s %d ms", phaseLabels[p.name], int(p.d/time.Millisecond))
+		fmt.Fprintf(w, `<rect x="%.1f" y="0" width="%.1f" height="%d" fill="%s"><title>%s</title></rect>`+"\n",
+			x, pw, barHeight, phaseColors[p.name], label)
+		fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="middle">%d ms</text>`+"\n",
+			x+pw/2, barHeight+(height-barHeight)/2+4, int(p.d/time.Millisecond))
+		x += pw
+	}
+}
+
+// StackedMs returns the duration of each phase in milliseconds in the order
+// of PhaseNames, for the segments of a stacked bar chart: the segments don't
+// overlap and add up to the total. Negative durations are clamped to 0.
+func (r *Result) StackedMs() []float64 {
+	ds := r.phaseDurations()
+	ms := make([]float64, len(ds))
+	for i, p := range ds {
+		ms[i] = float64(p.d) / float64(time.Millisecond)
+	}
+	return ms
+}
+
+// FormatTable writes results as a table with one row per Result and one
+// column per phase, in milliseconds.
+func FormatTable(w io.Writer, results []*Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	io.WriteString(tw, "#\t")
+	for _, p := range (&Result{}).phaseDurations() {
+		io.WriteString(tw, phaseLabels[p.name]+"\t")
+	}
+	io.WriteString(tw, "Total\t\n")
+
+	for i, r := range results {
+		fmt.Fprintf(tw, "%d\t", i+1)
+		for _, p := range r.phaseDurations() {
+			fmt.Fprintf(tw, "%d ms\t", int(p.d/time.Millisecond))
+		}
+		fmt.Fprintf(tw, "%d ms\t\n", int(r.total/time.Millisecond))
+	}
+	return tw.Flush()
+}
+
+// sparkBlocks are the characters of a sparkline from the lowest to the
+// highest value.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// CompareSparklines returns the duration of phase (one of PhaseNames or
+// "Total") of each of results, in order, as a sparkline of one character per
+// Result. The heights are relative to the largest duration, so a phase
+// creeping up over the runs shows as a rising line. It is "" for an unknown
+// phase.
+func CompareSparklines(results []*Result, phase string) string {
+	ds := phaseValues(results, phase)
+	var max time.Duration
+	for _, d := range ds {
+		if d > max {
+			max = d
+		}
+	}
+
+	line := make([]rune, len(ds))
+	for i, d := range ds {
+		level := 0
+		if max > 0 && d > 0 {
+			level = int((int64(d)*int64(len(sparkBlocks)-1) + int64(max)/2) / int64(max))
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
+
+// FormatMarkdown writes the duration of each phase and the total as a
+// Markdown table, for pasting into issues and runbooks. The columns are
+// padded to the same width on every row so the table also reads well as
+// plain text.
+func (r *Result) FormatMarkdown(w io.Writer) {
+	type row struct{ phase, duration string }
+	rows := []row{{"Phase", "Duration"}}
+	for _, p := range r.phaseDurations() {
+		rows = append(rows, row{phaseLabels[p.name], fmt.Sprintf("%d ms", int(p.d/time.Millisecond))})
+	}
+	rows = append(rows, row{"Total", fmt.Sprintf("%d ms", int(r.total/time.Millisecond))})
+
+	pw, dw := 0, 0
+	for _, row := range rows {
+		if len(row.phase) > pw {
+			pw = len(row.phase)
+		}
+		if len(row.duration) > dw {
+			dw = len(row.duration)
+		}
+	}
+
+	for i, row := range rows {
+		fmt.Fprintf(w, "| %-*s | %*s |\n", pw, row.phase, dw, row.duration)
+		if i == 0 {
+			// The colon right aligns the durations.
+			fmt.Fprintf(w, "| %s | %s: |\n", strings.Repeat("-", pw), strings.Repeat("-", dw-1))
+		}
+	}
+}
+
+// Logfmt returns r as a logfmt line with the duration of every phase and
+// the total, the IPs and the headers captured with WithCaptureHeaders:
+//
+//	dns=5.1ms tcp=7.2ms tls=20ms server=50.3ms transfer=5.4ms total=88ms remote_ip=192.0.2.1 server_header="Apache 2.4"
+//
+// Durations are formatted by time.Duration.String and can be parsed back with
+// time.ParseDuration. A header is keyed by its lowercase name with "-"
+// replaced by "_" and "_header" appended. Values are quoted when needed;
+// empty IPs are left out.
+func (r *Result) Logfmt() string {
+	var buf bytes.Buffer
+	pair := func(key, value string) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		if value == "" || strings.ContainsAny(value, " =\"\\") || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(value)
+	}
+
+	for _, p := range r.phaseDurations() {
+		pair(metricNames[p.name], p.d.String())
+	}
+	pair("total", r.total.String())
+	if r.localAddr != "" {
+		pair("local_ip", r.localAddr)
+	}
+	if r.remoteAddr != "" {
+		pair("remote_ip", r.remoteAddr)
+	}
+	for _, name := range r.cfg.captureHeaders {
+		if v, ok := r.CapturedHeaders[name]; ok {
+			pair(strings.Replace(strings.ToLower(name), "-", "_", -1)+"_header", v)
+		}
+	}
+	return buf.String()
+}
+
+// clfTimeFormat is the time format of the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// CommonLogLine returns r as a line in the Common Log Format followed by the
+// total and the phase durations in milliseconds:
+//
+//	<remote ip> - - [<start>] "-" <status> <body bytes> total=<ms> dns=<ms> tcp=<ms> tls=<ms> server=<ms> transfer=<ms>
+//
+// for example
+//
+//	192.0.2.1 - - [01/Jan/2018:00:00:00 +0000] "-" 200 1234 total=150 dns=10 tcp=20 tls=30 server=40 transfer=50
+//
+// The request line is "-" as the Result doesn't know the request. As in the
+// Common Log Format unknown fields are "-": the remote IP before a
+// connection, the status without SetResponse and the bytes without WrapBody.
+func (r *Result) CommonLogLine() string {
+	dash := func(s string) string {
+		if s == "" || s == "0" {
+			return "-"
+		}
+		return s
+	}
+	ts := "-"
+	if !r.start.IsZero() {
+		ts = r.start.Format(clfTimeFormat)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s - - [%s] \"-\" %s %s total=%d",
+		dash(r.remoteAddr), ts,
+		dash(strconv.Itoa(r.statusCode)),
+		dash(strconv.FormatInt(r.bodyBytes, 10)),
+		int(r.total/time.Millisecond))
+	for _, p := range r.phaseDurations() {
+		fmt.Fprintf(&buf, " %s=%d", metricNames[p.name], int(p.d/time.Millisecond))
+	}
+	return buf.String()
+}
@@ -0,0 +1,367 @@
+package httpstat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden compares got with testdata/name, rewriting the file instead
+// when the test runs with -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal("WriteFile failed:", err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("ReadFile failed:", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("expect to be eq:\n\nwant:\n\n%s\ngot:\n\n%s\n", want, got)
+	}
+}
+
+func TestFixedScaleBars(t *testing.T) {
+	fast := Result{
+		NameLookup:    20 * time.Millisecond,
+		Connect:       40 * time.Millisecond,
+		PreTransfer:   90 * time.Millisecond,
+		StartTransfer: 150 * time.Millisecond,
+		total:         170 * time.Millisecond,
+	}
+	slow := Result{
+		NameLookup:    20 * time.Millisecond,
+		Connect:       60 * time.Millisecond,
+		PreTransfer:   160 * time.Millisecond,
+		StartTransfer: 400 * time.Millisecond,
+		total:         455 * time.Millisecond,
+	}
+
+	var buf bytes.Buffer
+	fast.FixedScaleBars(&buf, 10)
+	slow.FixedScaleBars(&buf, 10)
+	assertGolden(t, "fixed_scale_bars.golden", buf.Bytes())
+}
+
+func TestFixedScaleBars_Wide(t *testing.T) {
+	r := Result{
+		NameLookup:    20 * time.Millisecond,
+		Connect:       40 * time.Millisecond,
+		PreTransfer:   90 * time.Millisecond,
+		StartTransfer: 12090 * time.Millisecond,
+		total:         12100 * time.Millisecond,
+	}
+
+	var buf bytes.Buffer
+	r.FixedScaleBars(&buf, 1000)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		if got, want := strings.Index(line, "|"), strings.Index(lines[0], "|"); got != want {
+			t.Fatalf("bar %d starts at %d, want %d:\n%s", i, got, want, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "12000 ms |############") {
+		t.Fatalf("expect the 12s phase to be written in full:\n%s", buf.String())
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	results := []*Result{
+		{
+			NameLookup:    5 * time.Millisecond,
+			Connect:       15 * time.Millisecond,
+			PreTransfer:   45 * time.Millisecond,
+			StartTransfer: 95 * time.Millisecond,
+			total:         100 * time.Millisecond,
+		},
+		{
+			NameLookup:    120 * time.Millisecond,
+			Connect:       250 * time.Millisecond,
+			PreTransfer:   600 * time.Millisecond,
+			StartTransfer: 1800 * time.Millisecond,
+			total:         12500 * time.Millisecond,
+		},
+		{
+			// Reused connection
+			StartTransfer: 30 * time.Millisecond,
+			total:         31 * time.Millisecond,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := FormatTable(&buf, results); err != nil {
+		t.Fatal("FormatTable failed:", err)
+	}
+	assertGolden(t, "format_table.golden", buf.Bytes())
+}
+
+func TestCompareSparklines(t *testing.T) {
+	var results []*Result
+	for _, tls := range []int{0, 10, 20, 30, 40, 50, 60, 70} {
+		results = append(results, &Result{
+			Connect:     10 * time.Millisecond,
+			PreTransfer: time.Duration(10+tls) * time.Millisecond,
+		})
+	}
+
+	if got, want := CompareSparklines(results, "TLSHandshake"), "▁▂▃▄▅▆▇█"; got != want {
+		t.Fatalf("sparkline is %q, want %q", got, want)
+	}
+	if got, want := CompareSparklines(results, "TCPConnection"), "████████"; got != want {
+		t.Fatalf("sparkline of a flat phase is %q, want %q", got, want)
+	}
+	if got := CompareSparklines(results, "DNSLookup"); got != "▁▁▁▁▁▁▁▁" {
+		t.Fatalf("sparkline of a zero phase is %q", got)
+	}
+	if got := CompareSparklines(results, "Unknown"); got != "" {
+		t.Fatalf("sparkline of an unknown phase is %q, want \"\"", got)
+	}
+}
+
+func TestFormatTree(t *testing.T) {
+	result := Result{
+		NameLookup:    12 * time.Millisecond,
+		Connect:       40 * time.Millisecond,
+		PreTransfer:   95 * time.Millisecond,
+		StartTransfer: 1300 * time.Millisecond,
+		total:         1450 * time.Millisecond,
+	}
+
+	var buf bytes.Buffer
+	result.FormatTree(&buf)
+	assertGolden(t, "format_tree.golden", buf.Bytes())
+}
+
+func TestStackedMs(t *testing.T) {
+	result := Result{
+		NameLookup:    12500 * time.Microsecond,
+		Connect:       40 * time.Millisecond,
+		PreTransfer:   95 * time.Millisecond,
+		StartTransfer: 1300 * time.Millisecond,
+		total:         1450 * time.Millisecond,
+	}
+	got := result.StackedMs()
+	want := []float64{12.5, 27.5, 55, 1205, 150}
+	if len(got) != len(want) {
+		t.Fatalf("StackedMs is %v, want %v", got, want)
+	}
+	var sum float64
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("StackedMs is %v, want %v", got, want)
+		}
+		sum += got[i]
+	}
+	if sum != 1450 {
+		t.Fatalf("StackedMs sums to %v, want the total 1450", sum)
+	}
+}
+
+func TestFormatMarkdown(t *testing.T) {
+	result := Result{
+		NameLookup:    12 * time.Millisecond,
+		Connect:       40 * time.Millisecond,
+		PreTransfer:   95 * time.Millisecond,
+		StartTransfer: 1300 * time.Millisecond,
+		total:         1450 * time.Millisecond,
+	}
+
+	var buf bytes.Buffer
+	result.FormatMarkdown(&buf)
+
+	// Every line is a row of two cells and the second one is the
+	// delimiter row.
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("got %d lines, want 8:\n%s", len(lines), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "| ") || !strings.HasSuffix(line, " |") || strings.Count(line, "|") != 3 {
+			t.Fatalf("line %d is not a table row: %q", i+1, line)
+		}
+		if len(line) != len(lines[0]) {
+			t.Fatalf("line %d is %d wide, want %d", i+1, len(line), len(lines[0]))
+		}
+	}
+	if strings.Trim(lines[1], "|-: ") != "" {
+		t.Fatalf("line 2 is not a delimiter row: %q", lines[1])
+	}
+
+	assertGolden(t, "format_markdown.golden", buf.Bytes())
+}
+
+func TestCommonLogLine(t *testing.T) {
+	result := Result{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       30 * time.Millisecond,
+		PreTransfer:   60 * time.Millisecond,
+		StartTransfer: 100 * time.Millisecond,
+		total:         150 * time.Millisecond,
+		remoteAddr:    "192.0.2.1",
+		start:         time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		statusCode:    200,
+		bodyBytes:     1234,
+	}
+
+	want := `192.0.2.1 - - [02/Jan/2018:03:04:05 +0000] "-" 200 1234 total=150 dns=10 tcp=20 tls=30 server=40 transfer=50`
+	if got := result.CommonLogLine(); got != want {
+		t.Fatalf("CommonLogLine is\n%s\nwant\n%s", got, want)
+	}
+
+	want = `- - - [-] "-" - - total=0 dns=0 tcp=0 tls=0 server=0 transfer=0`
+	if got := (&Result{}).CommonLogLine(); got != want {
+		t.Fatalf("CommonLogLine of empty result is\n%s\nwant\n%s", got, want)
+	}
+}
+
+// parseLogfmt parses a logfmt line of key=value pairs separated by spaces,
+// where values may be quoted Go strings.
+func parseLogfmt(t *testing.T, line string) map[string]string {
+	t.Helper()
+	pairs := make(map[string]string)
+	for line != "" {
+		i := strings.IndexByte(line, '=')
+		if i <= 0 || strings.ContainsAny(line[:i], " \"") {
+			t.Fatalf("no key at %q", line)
+		}
+		key := line[:i]
+		line = line[i+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			// Scan to the closing quote, skipping escaped characters.
+			end := 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				t.Fatalf("unterminated quoted value at %q", line)
+			}
+			var err error
+			if value, err = strconv.Unquote(line[:end+1]); err != nil {
+				t.Fatalf("bad quoted value at %q: %s", line, err)
+			}
+			line = line[end+1:]
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			value = line[:end]
+			line = line[end:]
+		}
+		if line != "" && !strings.HasPrefix(line, " ") {
+			t.Fatalf("no space after %s=%q", key, value)
+		}
+		line = strings.TrimPrefix(line, " ")
+		pairs[key] = value
+	}
+	return pairs
+}
+
+func TestLogfmt(t *testing.T) {
+	result := Result{
+		NameLookup:    5100 * time.Microsecond,
+		Connect:       12300 * time.Microsecond,
+		PreTransfer:   32300 * time.Microsecond,
+		StartTransfer: 82600 * time.Microsecond,
+		total:         88 * time.Millisecond,
+		remoteAddr:    "192.0.2.1",
+	}
+	WithCaptureHeaders("Server", "X-Cache")(&result.cfg)
+	result.CapturedHeaders = map[string]string{
+		"Server":  `Apache 2.4 "custom"`,
+		"X-Cache": "HIT",
+	}
+
+	line := result.Logfmt()
+	got := parseLogfmt(t, line)
+	want := map[string]string{
+		"dns":            "5.1ms",
+		"tcp":            "7.2ms",
+		"tls":            "20ms",
+		"server":         "50.3ms",
+		"transfer":       "5.4ms",
+		"total":          "88ms",
+		"remote_ip":      "192.0.2.1",
+		"server_header":  `Apache 2.4 "custom"`,
+		"x_cache_header": "HIT",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Logfmt is %s, want the keys of %v", line, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s is %q, want %q in %s", k, got[k], v, line)
+		}
+	}
+
+	// The durations round-trip.
+	for _, p := range result.phaseDurations() {
+		d, err := time.ParseDuration(got[metricNames[p.name]])
+		if err != nil || d != p.d {
+			t.Fatalf("%s parses as %s (%v), want %s", metricNames[p.name], d, err, p.d)
+		}
+	}
+}
+
+func TestSVGTimeline(t *testing.T) {
+	result := Result{
+		// A reused connection: no DNS, TCP nor TLS.
+		StartTransfer: 300 * time.Millisecond,
+		total:         400 * time.Millisecond,
+	}
+
+	var buf bytes.Buffer
+	result.SVGTimeline(&buf, 400, 60)
+
+	var svg struct {
+		XMLName xml.Name
+		Width   int `xml:"width,attr"`
+		Rects   []struct {
+			X     float64 `xml:"x,attr"`
+			Width float64 `xml:"width,attr"`
+			Title string  `xml:"title"`
+		} `xml:"rect"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("SVGTimeline is not valid XML: %s\n%s", err, buf.String())
+	}
+	if svg.XMLName.Local != "svg" || svg.XMLName.Space != "http://www.w3.org/2000/svg" || svg.Width != 400 {
+		t.Fatalf("root is %v of width %d, want an SVG of width 400", svg.XMLName, svg.Width)
+	}
+	if len(svg.Rects) != 2 {
+		t.Fatalf("got %d rects, want one per non-zero phase (2)", len(svg.Rects))
+	}
+	if r := svg.Rects[0]; r.X != 0 || r.Width != 300 || r.Title != "Server Processing 300 ms" {
+		t.Fatalf("first rect is %+v", r)
+	}
+	if r := svg.Rects[1]; r.X != 300 || r.Width != 100 || r.Title != "Content Transfer 100 ms" {
+		t.Fatalf("second rect is %+v", r)
+	}
+
+	buf.Reset()
+	(&Result{}).SVGTimeline(&buf, 400, 60)
+	svg.Rects = nil
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("SVGTimeline of empty result is not valid XML: %s\n%s", err, buf.String())
+	}
+	if len(svg.Rects) != 0 {
+		t.Fatalf("got %d rects for an empty result, want none", len(svg.Rects))
+	}
+}
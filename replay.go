@@ -0,0 +1,213 @@
+package httpstat
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// traceEvent is a hook call with the arguments the hooks of WithHTTPStat
+// use, enough to call the hook again in ReplayTrace.
+type traceEvent struct {
+	Hook string    `json:"hook"`
+	At   time.Time `json:"at"`
+
+	HostPort   string        `json:"host_port,omitempty"`   // GetConn
+	Network    string        `json:"network,omitempty"`     // ConnectStart, ConnectDone
+	Addr       string        `json:"addr,omitempty"`        // ConnectStart, ConnectDone
+	Err        string        `json:"err,omitempty"`         // ConnectDone, TLSHandshakeDone
+	TLSVersion uint16        `json:"tls_version,omitempty"` // TLSHandshakeDone
+	Resumed    bool          `json:"resumed,omitempty"`     // TLSHandshakeDone
+	ALPN       string        `json:"alpn,omitempty"`        // TLSHandshakeDone
+	Reused     bool          `json:"reused,omitempty"`      // GotConn
+	IdleTime   time.Duration `json:"idle_time,omitempty"`   // GotConn
+	TLS        bool          `json:"tls,omitempty"`         // GotConn
+	LocalAddr  string        `json:"local_addr,omitempty"`  // GotConn
+	RemoteAddr string        `json:"remote_addr,omitempty"` // GotConn
+	Code       int           `json:"code,omitempty"`        // Got1xxResponse
+}
+
+// eventLog records the hook calls in the order they happened. The hooks
+// are called from the read and write loops of the connection concurrently.
+type eventLog struct {
+	mu     sync.Mutex
+	events []traceEvent
+}
+
+// add records e. It does nothing on a nil log, the one of a Result which
+// WithHTTPStat was not called with.
+func (l *eventLog) add(e traceEvent) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+// snapshot returns a copy of the recorded events.
+func (l *eventLog) snapshot() []traceEvent {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]traceEvent(nil), l.events...)
+}
+
+// errString returns the message of err or "" when it is nil.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// WithTraceLog makes the hooks record every call with its time and
+// arguments, for DumpTrace and HookSequence. The log keeps growing with the
+// hops of redirected and retried requests, so it is off by default.
+func WithTraceLog() Option {
+	return func(c *config) {
+		c.traceLog = true
+	}
+}
+
+// traceDump is the serialized form of a trace.
+type traceDump struct {
+	Events []traceEvent `json:"events"`
+}
+
+// DumpTrace returns the hook calls recorded on r with WithTraceLog, with
+// their absolute times and the arguments the Result uses, and the call of
+// End, as JSON. Give it to ReplayTrace to rebuild the Result, for example to
+// capture a trace in production once and replay it in the tests of code
+// analyzing Results. Without WithTraceLog the trace has no events.
+//
+// What is recorded outside the hooks, like the response given to
+// SetResponse or the reads of WrapBody, is not part of the trace.
+func (r *Result) DumpTrace() []byte {
+	events := r.events.snapshot()
+	if len(events) > 0 {
+		// The durations were measured with the monotonic clock. Derive
+		// the times from the first one with it so that the wall clock
+		// times in the dump give the same durations.
+		base := events[0].At
+		for i := range events {
+			events[i].At = base.Round(0).Add(events[i].At.Sub(base))
+		}
+	}
+	b, _ := json.Marshal(traceDump{Events: events})
+	return b
+}
+
+// ReplayTrace calls the hooks of the trace returned by DumpTrace on r at the
+// recorded times, which gives r the durations of the Result which was
+// dumped. r is usually a new Result, options given to WithHTTPStat before
+// apply. The calls are recorded on r as with WithTraceLog.
+func ReplayTrace(data []byte, r *Result) error {
+	var dump traceDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return fmt.Errorf("httpstat: invalid trace: %v", err)
+	}
+	for _, e := range dump.Events {
+		if !replayHooks[e.Hook] {
+			return fmt.Errorf("httpstat: unknown hook %q in trace", e.Hook)
+		}
+	}
+
+	clock := r.cfg.clock
+	defer func() { r.cfg.clock = clock }()
+	var now time.Time
+	ctx := WithHTTPStat(context.Background(), r, WithTraceLog(), WithClock(func() time.Time {
+		return now
+	}))
+	trace := httptrace.ContextClientTrace(ctx)
+
+	for _, e := range dump.Events {
+		now = e.At
+		var err error
+		if e.Err != "" {
+			err = errors.New(e.Err)
+		}
+		switch e.Hook {
+		case "GetConn":
+			trace.GetConn(e.HostPort)
+		case "DNSStart":
+			trace.DNSStart(httptrace.DNSStartInfo{})
+		case "DNSDone":
+			trace.DNSDone(httptrace.DNSDoneInfo{})
+		case "ConnectStart":
+			trace.ConnectStart(e.Network, e.Addr)
+		case "ConnectDone":
+			trace.ConnectDone(e.Network, e.Addr, err)
+		case "TLSHandshakeStart":
+			trace.TLSHandshakeStart()
+		case "TLSHandshakeDone":
+			trace.TLSHandshakeDone(tls.ConnectionState{
+				Version:            e.TLSVersion,
+				DidResume:          e.Resumed,
+				NegotiatedProtocol: e.ALPN,
+				HandshakeComplete:  err == nil,
+			}, err)
+		case "GotConn":
+			var conn net.Conn = replayConn{local: e.LocalAddr, remote: e.RemoteAddr}
+			if e.TLS {
+				conn = tls.Client(conn, &tls.Config{})
+			}
+			trace.GotConn(httptrace.GotConnInfo{Conn: conn, Reused: e.Reused, IdleTime: e.IdleTime})
+		case "WroteHeaders":
+			trace.WroteHeaders()
+		case "WroteRequest":
+			trace.WroteRequest(httptrace.WroteRequestInfo{})
+		case "Got1xxResponse":
+			trace.Got1xxResponse(e.Code, nil)
+		case "GotFirstResponseByte":
+			trace.GotFirstResponseByte()
+		case "End":
+			r.End(now)
+		}
+	}
+	return nil
+}
+
+// replayHooks are the hook names ReplayTrace knows.
+var replayHooks = map[string]bool{
+	"GetConn": true, "DNSStart": true, "DNSDone": true,
+	"ConnectStart": true, "ConnectDone": true,
+	"TLSHandshakeStart": true, "TLSHandshakeDone": true,
+	"GotConn": true, "WroteHeaders": true, "WroteRequest": true,
+	"Got1xxResponse": true, "GotFirstResponseByte": true, "End": true,
+}
+
+// replayConn is the connection given to GotConn by ReplayTrace. Only its
+// addresses are used.
+type replayConn struct {
+	net.Conn
+	local, remote string
+}
+
+func (c replayConn) LocalAddr() net.Addr {
+	if c.local == "" {
+		return nil
+	}
+	return replayAddr(c.local)
+}
+
+func (c replayConn) RemoteAddr() net.Addr {
+	if c.remote == "" {
+		return nil
+	}
+	return replayAddr(c.remote)
+}
+
+// replayAddr is a recorded address.
+type replayAddr string
+
+func (a replayAddr) Network() string { return "tcp" }
+func (a replayAddr) String() string  { return string(a) }
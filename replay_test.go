@@ -0,0 +1,75 @@
+package httpstat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReplayTrace(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/redirect", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	res, err := srv.Client().Do(req.WithContext(WithHTTPStat(context.Background(), &result, WithTraceLog())))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+
+	dump := result.DumpTrace()
+	var replayed Result
+	if err := ReplayTrace(dump, &replayed); err != nil {
+		t.Fatal("ReplayTrace failed:", err)
+	}
+
+	if !reflect.DeepEqual(replayed.phaseDurations(), result.phaseDurations()) {
+		t.Fatalf("replayed phases are %v, want %v", replayed.phaseDurations(), result.phaseDurations())
+	}
+	if replayed.NameLookup != result.NameLookup || replayed.Connect != result.Connect ||
+		replayed.PreTransfer != result.PreTransfer || replayed.StartTransfer != result.StartTransfer ||
+		replayed.total != result.total {
+		t.Fatalf("replayed Result is %+v, want %+v", replayed, result)
+	}
+	if replayed.LocalIp() != result.LocalIp() || replayed.RemoteIP() != result.RemoteIP() ||
+		replayed.RemotePort() != result.RemotePort() || replayed.Reused() != result.Reused() ||
+		replayed.tlsConn != result.tlsConn || replayed.tlsVersion != result.tlsVersion {
+		t.Fatal("expect the replayed connection to be the one dumped")
+	}
+	if !reflect.DeepEqual(replayed.timelineEntries(), result.timelineEntries()) {
+		t.Fatalf("replayed timeline is %v, want %v", replayed.timelineEntries(), result.timelineEntries())
+	}
+	if got := replayed.DumpTrace(); !bytes.Equal(got, dump) {
+		t.Fatalf("dump of the replayed Result is\n%s\nwant\n%s", got, dump)
+	}
+}
+
+func TestReplayTrace_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"events":[{"hook":"Unknown","at":"2018-01-01T00:00:00Z"}]}`,
+	} {
+		var result Result
+		if err := ReplayTrace([]byte(data), &result); err == nil {
+			t.Fatalf("expect ReplayTrace of %s to fail", data)
+		}
+	}
+}
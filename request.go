@@ -0,0 +1,30 @@
+package httpstat
+
+import (
+	"net/http"
+	"time"
+)
+
+// SetRequest records the parts of the request which the trace can't see.
+// Call it right before sending req with client, which may be nil. The
+// request and the client are not retained.
+//
+// It sets EffectiveTimeout to the tighter of client.Timeout and the time
+// left until the deadline of the context of req, so that a failure can be
+// told apart as "failed after 30s of a 30s timeout".
+func (r *Result) SetRequest(req *http.Request, client *http.Client) {
+	var timeout time.Duration
+	if client != nil && client.Timeout > 0 {
+		timeout = client.Timeout
+	}
+	if deadline, ok := req.Context().Deadline(); ok {
+		left := deadline.Sub(r.now())
+		if left < 0 {
+			left = 0
+		}
+		if timeout == 0 || left < timeout {
+			timeout = left
+		}
+	}
+	r.EffectiveTimeout = timeout
+}
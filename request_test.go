@@ -0,0 +1,48 @@
+package httpstat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetRequest(t *testing.T) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+
+	var result Result
+	result.SetRequest(req, client)
+	if result.EffectiveTimeout != 30*time.Second {
+		t.Fatalf("EffectiveTimeout is %s, want the client timeout 30s", result.EffectiveTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result.SetRequest(req.WithContext(ctx), client)
+	if result.EffectiveTimeout > 5*time.Second || result.EffectiveTimeout < 4*time.Second {
+		t.Fatalf("EffectiveTimeout is %s, want the context deadline of about 5s", result.EffectiveTimeout)
+	}
+	if got := fmt.Sprintf("%+v", result); !strings.Contains(got, "Timeout:        5000 ms\n") &&
+		!strings.Contains(got, "Timeout:        4999 ms\n") {
+		t.Fatalf("expect verbose Format to show the timeout:\n%s", got)
+	}
+
+	// A looser deadline doesn't override the client timeout.
+	loose, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	result.SetRequest(req.WithContext(loose), client)
+	if result.EffectiveTimeout != 30*time.Second {
+		t.Fatalf("EffectiveTimeout is %s, want the client timeout 30s", result.EffectiveTimeout)
+	}
+
+	result.SetRequest(req, nil)
+	if result.EffectiveTimeout != 0 {
+		t.Fatalf("EffectiveTimeout without timeouts is %s, want 0", result.EffectiveTimeout)
+	}
+}
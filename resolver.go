@@ -0,0 +1,108 @@
+package httpstat
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// resultKey is the context key of the Result given to WithHTTPStat.
+type resultKey struct{}
+
+// fromContext returns the Result traced by ctx or nil.
+func fromContext(ctx context.Context) *Result {
+	r, _ := ctx.Value(resultKey{}).(*Result)
+	return r
+}
+
+// WrapResolver returns a resolver like res which records DNSQueueTime, the time a
+// name lookup waited before its first query was sent. Lookups may queue
+// behind others for the same host, which DNSStart and DNSDone alone can't
+// tell. With WithCaptureDNSServer it also records DNSServer.
+//
+// httptrace only sees the lookups of the Dialer, so the resolver must be set
+// on the Dialer of the transport:
+//
+//	dialer := &net.Dialer{Resolver: httpstat.WrapResolver(net.DefaultResolver)}
+//	transport := &http.Transport{DialContext: dialer.DialContext}
+//
+// The returned resolver always uses the pure Go resolver since only that one
+// dials through Resolver.Dial. When res.Dial is nil a net.Dialer is used.
+func WrapResolver(res *net.Resolver) *net.Resolver {
+	dial := res.Dial
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+
+	return &net.Resolver{
+		PreferGo:     true,
+		StrictErrors: res.StrictErrors,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if r := fromContext(ctx); r != nil {
+				r.queryStarted(r.now())
+				if r.cfg.captureDNSServer {
+					r.dnsServer.Store(address)
+				}
+			}
+			return dial(ctx, network, address)
+		},
+	}
+}
+
+// queryStarted records the queue time when the first query of a lookup is
+// sent at t. Later queries of the same lookup are ignored.
+func (r *Result) queryStarted(t time.Time) {
+	start := atomic.SwapInt64(&r.lookupStart, 0)
+	if start == 0 {
+		return
+	}
+	r.DNSQueueTime += t.Sub(time.Unix(0, start))
+}
+
+// DNSServer returns the address (host:port) of the DNS server which answered
+// the name lookup, which tells which resolver of a split horizon setup was
+// used. httptrace doesn't report it: it needs WithCaptureDNSServer and the
+// resolver returned by WrapResolver, see there for the wiring. It is "" when
+// no query was traced.
+//
+// When a server fails to answer the resolver queries the next one, so the
+// server recorded is the last one queried.
+func (r *Result) DNSServer() string {
+	s, _ := r.dnsServer.Load().(string)
+	return s
+}
+
+// TimeDoH calls resolve, a lookup with DNS over HTTPS, and adds the time it
+// took to DoHResolveTime of the Result traced by ctx. httptrace can't see
+// such a lookup: it is an HTTPS request of its own made by a custom dialer,
+// which dials the resolved IP so that no DNSStart fires. Call it from the
+// DialContext of the transport with the context it was given:
+//
+//	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+//		host, port, _ := net.SplitHostPort(addr)
+//		var ip string
+//		err := httpstat.TimeDoH(ctx, func() (err error) {
+//			ip, err = doh.Lookup(context.Background(), host)
+//			return err
+//		})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+//	}
+//
+// The DoH request must not be sent with ctx, or its own hooks would be
+// recorded on the Result of the outer request. It returns the error of
+// resolve.
+func TimeDoH(ctx context.Context, resolve func() error) error {
+	r := fromContext(ctx)
+	if r == nil {
+		return resolve()
+	}
+	start := r.now()
+	err := resolve()
+	r.DoHResolveTime += r.now().Sub(start)
+	return err
+}
@@ -0,0 +1,130 @@
+package httpstat
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestWrapResolver_DNSQueueTime(t *testing.T) {
+	var dialed int
+	stub := &net.Resolver{
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed++
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+	res := WrapResolver(stub)
+	if !res.PreferGo {
+		t.Fatal("expect wrapped resolver to prefer the Go resolver")
+	}
+
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result)
+	trace := httptrace.ContextClientTrace(ctx)
+
+	queued := 30 * time.Millisecond
+	trace.DNSStart(httptrace.DNSStartInfo{Host: "example.com"})
+	time.Sleep(queued)
+	for i := 0; i < 2; i++ {
+		conn, err := res.Dial(ctx, "udp", "192.0.2.53:53")
+		if err != nil {
+			t.Fatal("Dial failed:", err)
+		}
+		conn.Close()
+	}
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+
+	if dialed != 2 {
+		t.Fatalf("stub dialed %d times, want 2", dialed)
+	}
+	if result.DNSQueueTime < queued || result.DNSQueueTime > result.NameLookup {
+		t.Fatalf("DNSQueueTime is %s, want at least %s and at most %s",
+			result.DNSQueueTime, queued, result.NameLookup)
+	}
+}
+
+func TestWrapResolver_DNSServer(t *testing.T) {
+	stub := &net.Resolver{
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+	res := WrapResolver(stub)
+
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{WithCaptureDNSServer()}, "192.0.2.53:53"},
+		{nil, ""},
+	} {
+		var result Result
+		ctx := WithHTTPStat(context.Background(), &result, tc.opts...)
+		trace := httptrace.ContextClientTrace(ctx)
+
+		trace.DNSStart(httptrace.DNSStartInfo{Host: "example.com"})
+		conn, err := res.Dial(ctx, "udp", "192.0.2.53:53")
+		if err != nil {
+			t.Fatal("Dial failed:", err)
+		}
+		conn.Close()
+		trace.DNSDone(httptrace.DNSDoneInfo{})
+
+		if got := result.DNSServer(); got != tc.want {
+			t.Fatalf("DNSServer is %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestTimeDoH(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	// A stub DoH resolver which answers 127.0.0.1 after 30ms.
+	dohLookup := func(host string) (string, error) {
+		time.Sleep(30 * time.Millisecond)
+		return "127.0.0.1", nil
+	}
+	var dialer net.Dialer
+	transport := DefaultTransport()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		var ip string
+		err := TimeDoH(ctx, func() (err error) {
+			ip, err = dohLookup(host)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+	}
+
+	result := do(t, &http.Client{Transport: transport}, "http://doh.example:"+port+"/")
+	if result.DoHResolveTime < 30*time.Millisecond {
+		t.Fatalf("DoHResolveTime is %s, want at least 30ms", result.DoHResolveTime)
+	}
+	if result.NameLookup != 0 {
+		t.Fatalf("NameLookup is %s, want 0 as the dialer dialed an IP", result.NameLookup)
+	}
+
+	// Without a traced context resolve is just called.
+	called := false
+	TimeDoH(context.Background(), func() error { called = true; return nil })
+	if !called {
+		t.Fatal("expect resolve to be called")
+	}
+}
@@ -0,0 +1,249 @@
+package httpstat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SetResponse records the parts of res which the trace can't see. Call it
+// with the response returned by the client. The response itself is not
+// retained.
+//
+// When res has no body (the response to a HEAD request or one with a
+// Content-Length of 0) the request is complete with its first byte: Total
+// is StartTransfer and ContentTransfer is 0, however late End is called.
+func (r *Result) SetResponse(res *http.Response) {
+	r.statusCode = res.StatusCode
+	r.retryAfter = parseRetryAfter(res.Header)
+	r.noBody = res.ContentLength == 0 || res.Request != nil && res.Request.Method == http.MethodHead
+	r.pinNoBody()
+	r.ResponseHeaderBytes = headerBytes(res)
+	r.proto = res.Proto
+	// The transport removes the Content-Encoding of a body it decompresses.
+	r.uncompressed = res.Uncompressed
+	r.contentEncoding = res.Header.Get("Content-Encoding")
+	if res.Uncompressed {
+		r.contentEncoding = "gzip"
+	}
+	// The transport removes "Connection: close" from the header and sets
+	// res.Close instead.
+	r.closed = res.Close || headerHasToken(res.Header, "Connection", "close")
+
+	for _, name := range r.cfg.captureHeaders {
+		if vs, ok := res.Header[name]; ok {
+			if r.CapturedHeaders == nil {
+				r.CapturedHeaders = make(map[string]string)
+			}
+			r.CapturedHeaders[name] = strings.Join(vs, ", ")
+		}
+	}
+}
+
+// SetResponseAt is SetResponse for a response whose headers were parsed at
+// t, which sets HeadersParsed. The trace only sees the first byte of the
+// response, so the caller has to take t right after the client returned
+// res:
+//
+//	res, err := client.Do(req)
+//	parsed := time.Now()
+//	...
+//	result.SetResponseAt(res, parsed)
+//
+// The gap from StartTransfer is the time spent reading and parsing the
+// headers, which matters for responses with many or large headers.
+func (r *Result) SetResponseAt(res *http.Response, t time.Time) {
+	r.SetResponse(res)
+	if !r.start.IsZero() {
+		r.HeadersParsed = t.Sub(r.start)
+	}
+}
+
+// headerBytes returns the size of the status line, the headers and the
+// blank line after them of res serialized as HTTP/1.1. For an HTTP/2
+// response, whose headers are compressed on the wire, it is the size they
+// would have had.
+func headerBytes(res *http.Response) int64 {
+	var cw countingWriter
+	// The status line, like "HTTP/1.1 200 OK\r\n".
+	fmt.Fprintf(&cw, "HTTP/%d.%d %s\r\n", res.ProtoMajor, res.ProtoMinor, res.Status)
+	res.Header.Write(&cw)
+	io.WriteString(&cw, "\r\n")
+	return int64(cw)
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// pinNoBody ends a response without a body at its first byte. It is called
+// by both SetResponse and End as they can be called in any order.
+func (r *Result) pinNoBody() {
+	if r.noBody && r.total > 0 && r.StartTransfer > 0 {
+		r.total = r.StartTransfer
+	}
+}
+
+// SetError records err, the error the client returned instead of a
+// response, see Err. The Transport of this package and TracedGet record it
+// themselves.
+func (r *Result) SetError(err error) {
+	r.err = err
+}
+
+// Err returns the error given to SetError, nil when the request got a
+// response.
+func (r *Result) Err() error {
+	return r.err
+}
+
+// headerHasToken reports whether the comma separated values of header name
+// contain token, compared case insensitively.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServerClosedConnection reports whether the response given to SetResponse
+// asked to close the connection after it, with "Connection: close". The
+// next request to the server can't reuse the connection and has to open a
+// new one.
+func (r *Result) ServerClosedConnection() bool {
+	return r.closed
+}
+
+// Compression returns the Content-Encoding of the response given to
+// SetResponse, like "gzip" or "br", also when the transport decompressed the
+// body transparently (see Decompressed). It is "" for an uncompressed
+// response.
+func (r *Result) Compression() string {
+	return r.contentEncoding
+}
+
+// Decompressed reports whether the transport decompressed the body of the
+// response given to SetResponse transparently, which it does for gzip when
+// the request didn't set Accept-Encoding.
+func (r *Result) Decompressed() bool {
+	return r.uncompressed
+}
+
+// CompressionRatio returns the size of the compressed body over the size of
+// the decompressed one, for example 0.25 for a body compressed to a quarter:
+// a small ratio explains a transfer much faster than the size of the
+// payload suggests. It needs the compressed body to be wrapped by WrapBody
+// and the decompressing reader by WrapDecoded:
+//
+//	res.Body = result.WrapBody(res.Body)
+//	zr, err := gzip.NewReader(res.Body)
+//	...
+//	io.Copy(dst, result.WrapDecoded(zr))
+//
+// It is 0 otherwise, and when the transport decompressed the body as the
+// compressed size is then unknown.
+func (r *Result) CompressionRatio() float64 {
+	if r.uncompressed || r.bodyBytes <= 0 || r.decodedBytes <= 0 {
+		return 0
+	}
+	return float64(r.bodyBytes) / float64(r.decodedBytes)
+}
+
+// StoppedAtRedirect reports whether the response given to SetResponse is a
+// redirect, which means the client didn't follow it (for example because
+// CheckRedirect returned http.ErrUseLastResponse) and the Result only covers
+// the hops up to it.
+func (r *Result) StoppedAtRedirect() bool {
+	switch r.statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// RetryAfter returns how long the server asked to wait before retrying with
+// the Retry-After header of the response given to SetResponse, usually on a
+// 429 or 503. It returns 0 when there was no valid header.
+func (r *Result) RetryAfter() time.Duration {
+	return r.retryAfter
+}
+
+// parseRetryAfter parses the Retry-After header of h, which is either a
+// number of seconds or an HTTP date. A date is relative to the Date header of
+// the response when there is one, which avoids depending on clock skew.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	at, err := http.ParseTime(v)
+	if err != nil {
+		return 0
+	}
+	now, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		now = time.Now()
+	}
+	if d := at.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// GotEarlyHints reports whether the server sent a 103 Early Hints response
+// before the final one. GotFirstResponseByte fires for the first byte of the
+// 103, so StartTransfer then measures the hints rather than the final
+// response. Go servers only send a 103 written with WriteHeader from go1.19.
+func (r *Result) GotEarlyHints() bool {
+	return !r.earlyHints.IsZero()
+}
+
+// EarlyHintsAt returns the offset from the start of the request at which
+// the 103 Early Hints response arrived, or 0 when there was none.
+func (r *Result) EarlyHintsAt() time.Duration {
+	if r.earlyHints.IsZero() {
+		return 0
+	}
+	return r.earlyHints.Sub(r.start)
+}
+
+// RecordPush records that the server pushed a stream (HTTP/2 server push)
+// for the request traced by ctx, see ReceivedPush. It does nothing when ctx
+// is not traced.
+//
+// Server push is deprecated and neither net/http nor httptrace support it:
+// the client of net/http disables push in its settings. Clients which do
+// accept pushed streams call RecordPush from their push handler, with the
+// context of the request the push was promised on.
+func RecordPush(ctx context.Context) {
+	if r := fromContext(ctx); r != nil {
+		atomic.AddUint32(&r.pushes, 1)
+	}
+}
+
+// ReceivedPush reports whether the server pushed a stream for the request.
+// It needs RecordPush to be called by the push handler of the client.
+func (r *Result) ReceivedPush() bool {
+	return atomic.LoadUint32(&r.pushes) > 0
+}
@@ -0,0 +1,369 @@
+package httpstat
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// do sends a traced GET to url with client and records the response.
+func do(t *testing.T, client *http.Client, url string) *Result {
+	var result Result
+	res, err := client.Do(NewRequest(t, url, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+	result.SetResponse(res)
+	return &result
+}
+
+func TestStoppedAtRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := DefaultClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	if result := do(t, client, srv.URL+"/redirect"); !result.StoppedAtRedirect() {
+		t.Fatal("expect to stop at redirect")
+	}
+
+	if result := do(t, DefaultClient(), srv.URL+"/redirect"); result.StoppedAtRedirect() {
+		t.Fatal("expect redirect to be followed")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	date := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Retry-After": {"120"}}, 120 * time.Second},
+		{http.Header{
+			"Date":        {date.Format(http.TimeFormat)},
+			"Retry-After": {date.Add(90 * time.Second).Format(http.TimeFormat)},
+		}, 90 * time.Second},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{}, 0},
+	}
+
+	for _, tc := range cases {
+		var result Result
+		result.SetResponse(&http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     tc.header,
+		})
+		if got := result.RetryAfter(); got != tc.want {
+			t.Fatalf("RetryAfter for %v is %s, want %s", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter_Server(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	if got, want := do(t, DefaultClient(), srv.URL).RetryAfter(), 3*time.Second; got != want {
+		t.Fatalf("RetryAfter is %s, want %s", got, want)
+	}
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	var result Result
+	WithHTTPStat(context.Background(), &result, WithCaptureHeaders("server", "Via", "X-Cache"))
+	result.SetResponse(&http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Server":       {"nginx"},
+			"X-Cache":      {"HIT"},
+			"Content-Type": {"text/plain"},
+		},
+	})
+
+	want := map[string]string{"Server": "nginx", "X-Cache": "HIT"}
+	if len(result.CapturedHeaders) != len(want) {
+		t.Fatalf("CapturedHeaders is %v, want %v", result.CapturedHeaders, want)
+	}
+	for k, v := range want {
+		if result.CapturedHeaders[k] != v {
+			t.Fatalf("CapturedHeaders[%s] is %q, want %q", k, result.CapturedHeaders[k], v)
+		}
+	}
+
+	got := fmt.Sprintf("%+v", result)
+	if !strings.HasSuffix(got, "Server:         nginx\nX-Cache:        HIT\n") {
+		t.Fatalf("expect verbose format to show captured headers:\n%s", got)
+	}
+	if strings.Contains(fmt.Sprintf("%v", result), "nginx") {
+		t.Fatal("expect captured headers only in verbose format")
+	}
+}
+
+func TestSetResponse_Head(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("HEAD", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	res, err := DefaultClient().Do(req.WithContext(WithHTTPStat(req.Context(), &result)))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	res.Body.Close()
+	result.SetResponse(res)
+	time.Sleep(10 * time.Millisecond)
+	result.End(time.Now())
+
+	if got := result.ContentTransfer(); got != 0 {
+		t.Fatalf("ContentTransfer is %s, want 0", got)
+	}
+	if got := result.Total(time.Now()); got != result.StartTransfer {
+		t.Fatalf("Total is %s, want StartTransfer %s", got, result.StartTransfer)
+	}
+
+	// A GET with a body is not pinned.
+	get := do(t, DefaultClient(), srv.URL)
+	if got := get.Total(time.Now()); got <= get.StartTransfer {
+		t.Fatalf("Total of a GET is %s, want more than StartTransfer %s", got, get.StartTransfer)
+	}
+}
+
+func TestRecordPush(t *testing.T) {
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result)
+	if result.ReceivedPush() {
+		t.Fatal("expect no push before RecordPush")
+	}
+
+	// A push handler running on the connection's goroutine.
+	done := make(chan struct{})
+	go func() {
+		RecordPush(ctx)
+		close(done)
+	}()
+	<-done
+	if !result.ReceivedPush() {
+		t.Fatal("expect RecordPush to be recorded")
+	}
+
+	// An untraced context is ignored.
+	RecordPush(context.Background())
+}
+
+func TestSetResponse_HeaderBytes(t *testing.T) {
+	big := strings.Repeat("x", 8000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Big", big)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	// Count the header bytes on the wire.
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal("Dial failed:", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	var wire int64
+	br := bufio.NewReader(conn)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal("ReadString failed:", err)
+		}
+		wire += int64(len(line))
+		if line == "\r\n" {
+			break
+		}
+	}
+
+	result := do(t, DefaultClient(), srv.URL)
+	if result.ResponseHeaderBytes != wire {
+		t.Fatalf("ResponseHeaderBytes is %d, want %d as on the wire", result.ResponseHeaderBytes, wire)
+	}
+	if result.ResponseHeaderBytes < int64(len(big)) {
+		t.Fatalf("ResponseHeaderBytes is %d, want more than the %d bytes of X-Big", result.ResponseHeaderBytes, len(big))
+	}
+}
+
+func TestUntrackedTime_Redirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The second hop reuses the connection of the first one, or dials a
+	// new one without keep-alives.
+	for _, keepAlive := range []bool{true, false} {
+		transport := DefaultTransport()
+		transport.DisableKeepAlives = !keepAlive
+		client := &http.Client{Transport: transport}
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			// Slow redirect handling in the client.
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}
+		result := do(t, client, srv.URL+"/redirect")
+		transport.CloseIdleConnections()
+
+		if result.Reused() != keepAlive {
+			t.Fatalf("Reused is %v with keep-alive %v", result.Reused(), keepAlive)
+		}
+		if got := result.UntrackedTime(); got < 50*time.Millisecond {
+			t.Fatalf("UntrackedTime with keep-alive %v is %s, want at least the 50ms of CheckRedirect", keepAlive, got)
+		}
+		if got := result.Durations()["TCPConnection"]; got >= 50*time.Millisecond {
+			t.Fatalf("TCPConnection with keep-alive %v is %s, want it without CheckRedirect", keepAlive, got)
+		}
+	}
+}
+
+func TestServerClosedConnection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/close" {
+			w.Header().Set("Connection", "close")
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := DefaultClient()
+	closed := do(t, client, srv.URL+"/close")
+	if !closed.ServerClosedConnection() {
+		t.Fatal("expect ServerClosedConnection with Connection: close")
+	}
+	if got := fmt.Sprintf("%+v", closed); !strings.Contains(got, "Connection:     closed by server\n") {
+		t.Fatalf("expect verbose Format to show the closed connection:\n%s", got)
+	}
+
+	// The next request can't reuse the connection.
+	if next := do(t, client, srv.URL); next.Reused() {
+		t.Fatal("expect the request after Connection: close to open a new connection")
+	} else if next.ServerClosedConnection() {
+		t.Fatal("expect no ServerClosedConnection without Connection: close")
+	}
+}
+
+func TestSetResponseAt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 100; i++ {
+			w.Header().Add("X-Header", strings.Repeat("x", 100))
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var result Result
+	res, err := DefaultClient().Do(NewRequest(t, srv.URL, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	parsed := time.Now()
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+	result.SetResponseAt(res, parsed)
+
+	if result.HeadersParsed <= 0 || result.HeadersParsed < result.StartTransfer {
+		t.Fatalf("HeadersParsed is %s, want it after StartTransfer %s", result.HeadersParsed, result.StartTransfer)
+	}
+	if result.HeadersParsed > result.Total(time.Now()) {
+		t.Fatalf("HeadersParsed is %s, want it before the total %s", result.HeadersParsed, result.Total(time.Now()))
+	}
+	if result.statusCode != http.StatusOK {
+		t.Fatal("expect SetResponseAt to record the response like SetResponse")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	payload := strings.Repeat("compressible ", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, payload)
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	// With Accept-Encoding set the caller decompresses the body.
+	var result Result
+	req := NewRequest(t, srv.URL, &result)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := DefaultClient().Do(req)
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	result.SetResponse(res)
+	res.Body = result.WrapBody(res.Body)
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal("gzip.NewReader failed:", err)
+	}
+	b, err := ioutil.ReadAll(result.WrapDecoded(zr))
+	if err != nil || string(b) != payload {
+		t.Fatal("expect to read the payload, got error", err)
+	}
+	res.Body.Close()
+
+	if got := result.Compression(); got != "gzip" || result.Decompressed() {
+		t.Fatalf("Compression is %q, decompressed %v, want gzip decompressed by the caller", got, result.Decompressed())
+	}
+	want := float64(result.bodyBytes) / float64(len(payload))
+	if got := result.CompressionRatio(); got != want || got <= 0 || got >= 0.1 {
+		t.Fatalf("CompressionRatio is %v, want %v", got, want)
+	}
+
+	// Without it the transport decompresses the body transparently.
+	var transparent Result
+	res, err = DefaultClient().Do(NewRequest(t, srv.URL, &transparent))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	transparent.SetResponse(res)
+	ioutil.ReadAll(transparent.WrapBody(res.Body))
+	res.Body.Close()
+	if transparent.Compression() != "gzip" || !transparent.Decompressed() {
+		t.Fatalf("Compression is %q, decompressed %v, want gzip decompressed by the transport",
+			transparent.Compression(), transparent.Decompressed())
+	}
+	if got := transparent.CompressionRatio(); got != 0 {
+		t.Fatalf("CompressionRatio of a transparently decompressed body is %v, want 0", got)
+	}
+}
@@ -0,0 +1,34 @@
+package httpstat
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EndAttempt marks the end of one attempt of a retried request. Retrying
+// clients call it when they give up on an attempt, before backing off. The
+// time until the next attempt starts is added to TotalBackoff.
+//
+// The same Result (and context) must be used for every attempt.
+func (r *Result) EndAttempt(t time.Time) {
+	atomic.StoreInt64(&r.attemptDone, t.UnixNano())
+}
+
+// Attempts returns the number of attempts traced so far.
+func (r *Result) Attempts() int {
+	return int(atomic.LoadInt32(&r.attempts))
+}
+
+// beginAttempt is called from the first hooks of a connection. It starts a
+// new attempt when the previous one was ended by EndAttempt. The hooks of
+// concurrent dials call it at the same time, only one of them starts the
+// attempt.
+func (r *Result) beginAttempt(t time.Time) {
+	atomic.CompareAndSwapInt32(&r.attempts, 0, 1)
+	done := atomic.SwapInt64(&r.attemptDone, 0)
+	if done == 0 {
+		return
+	}
+	r.TotalBackoff += t.Sub(time.Unix(0, done))
+	atomic.AddInt32(&r.attempts, 1)
+}
@@ -0,0 +1,86 @@
+package httpstat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTotalBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result)
+	client := DefaultClient()
+
+	// The backoff is bounded by the times recorded around it rather than
+	// by the sleep, which a loaded machine may stretch.
+	backoff := 100 * time.Millisecond
+	var ended, resent, answered time.Time
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed:", err)
+		}
+		if i == 1 {
+			resent = time.Now()
+		}
+		res, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal("client.Do failed:", err)
+		}
+		if i == 1 {
+			answered = time.Now()
+		}
+		if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+			t.Fatal("io.Copy failed:", err)
+		}
+		res.Body.Close()
+
+		if i == 0 {
+			ended = time.Now()
+			result.EndAttempt(ended)
+			time.Sleep(backoff)
+		}
+	}
+	result.End(time.Now())
+
+	if got, want := result.Attempts(), 2; got != want {
+		t.Fatalf("Attempts is %d, want %d", got, want)
+	}
+	if min, max := resent.Sub(ended), answered.Sub(ended); result.TotalBackoff < min || result.TotalBackoff > max {
+		t.Fatalf("TotalBackoff is %s, want between %s and %s", result.TotalBackoff, min, max)
+	}
+}
+
+func TestBeginAttempt_Concurrent(t *testing.T) {
+	// Happy eyeballs dials call ConnectStart from their own goroutines.
+	var result Result
+	start := time.Now()
+	result.EndAttempt(start)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.beginAttempt(start.Add(time.Second))
+		}()
+	}
+	wg.Wait()
+
+	if got, want := result.Attempts(), 2; got != want {
+		t.Fatalf("Attempts is %d, want %d", got, want)
+	}
+	if result.TotalBackoff != time.Second {
+		t.Fatalf("TotalBackoff is %s, want 1s", result.TotalBackoff)
+	}
+}
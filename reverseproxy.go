@@ -0,0 +1,29 @@
+package httpstat
+
+import (
+	"net/http"
+	"net/http/httputil"
+)
+
+// TraceReverseProxy makes p trace the requests it sends upstream and call
+// onResult with each outbound request and its Result, for example to log
+// the upstream timings next to the ones of the proxy itself:
+//
+//	proxy := httputil.NewSingleHostReverseProxy(upstream)
+//	httpstat.TraceReverseProxy(proxy, func(out *http.Request, r *httpstat.Result) {
+//		log.Printf("%s %s: %+v", out.Method, out.URL, r)
+//	})
+//
+// The ReverseProxy builds the outbound request itself, its Director and
+// Rewrite hooks can't trace it. TraceReverseProxy wraps the transport of p
+// (http.DefaultTransport when nil) in a Transport with opts instead, so
+// call it after setting p.Transport. onResult is called once the response
+// body was copied to the client, or when the upstream request failed, in
+// which case the Result has the error, see Err.
+func TraceReverseProxy(p *httputil.ReverseProxy, onResult func(out *http.Request, r *Result), opts ...Option) {
+	p.Transport = &Transport{
+		Base:     p.Transport,
+		Options:  opts,
+		OnResult: onResult,
+	}
+}
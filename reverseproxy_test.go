@@ -0,0 +1,60 @@
+package httpstat
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTraceReverseProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal("url.Parse failed:", err)
+	}
+
+	type traced struct {
+		out *http.Request
+		r   *Result
+	}
+	results := make(chan traced, 1)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = DefaultTransport()
+	TraceReverseProxy(proxy, func(out *http.Request, r *Result) {
+		results <- traced{out, r}
+	})
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+
+	res, err := http.Get(front.URL + "/path")
+	if err != nil {
+		t.Fatal("http.Get failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	var got traced
+	select {
+	case got = <-results:
+	case <-time.After(time.Second):
+		t.Fatal("expect the upstream request to be reported")
+	}
+	if got.out.URL.Host != target.Host || got.out.URL.Path != "/path" {
+		t.Fatalf("reported request is for %s, want the upstream one", got.out.URL)
+	}
+	if got.r.StartTransfer < 20*time.Millisecond || got.r.Total(time.Now()) < got.r.StartTransfer {
+		t.Fatalf("expect the upstream timings to be captured: %+v", got.r)
+	}
+	if got.r.RemoteIP() != "127.0.0.1" || got.r.statusCode != http.StatusOK {
+		t.Fatalf("Result is for %s with status %d, want the upstream response", got.r.RemoteIP(), got.r.statusCode)
+	}
+}
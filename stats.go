@@ -0,0 +1,103 @@
+package httpstat
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// phaseValue returns the duration of the named phase of r, or the total
+// for "Total".
+func phaseValue(r *Result, phase string) (time.Duration, bool) {
+	if phase == "Total" {
+		return r.total, true
+	}
+	for _, p := range r.phaseDurations() {
+		if p.name == phase {
+			return p.d, true
+		}
+	}
+	return 0, false
+}
+
+// phaseValues returns the duration of the named phase of each Result.
+func phaseValues(results []*Result, phase string) []time.Duration {
+	ds := make([]time.Duration, 0, len(results))
+	for _, r := range results {
+		if d, ok := phaseValue(r, phase); ok {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// mean returns the mean of ds or 0 when it is empty.
+func mean(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return sum / time.Duration(len(ds))
+}
+
+// percentile returns the p-th percentile (0-100) of ds with the nearest-rank
+// method, or 0 when ds is empty.
+func percentile(ds []time.Duration, p float64) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	sorted := sortedCopy(ds)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// sortedCopy returns ds sorted in increasing order, leaving ds untouched.
+func sortedCopy(ds []time.Duration) []time.Duration {
+	sorted := make([]time.Duration, len(ds))
+	copy(sorted, ds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// interpolatedPercentile returns the p-th percentile (0-100) of sorted, an
+// increasing slice, interpolating linearly between the closest ranks, or 0
+// when sorted is empty. Unlike percentile it changes smoothly with p.
+func interpolatedPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p / 100 * float64(len(sorted)-1)
+	if pos <= 0 {
+		return sorted[0]
+	}
+	if pos >= float64(len(sorted)-1) {
+		return sorted[len(sorted)-1]
+	}
+	lo := int(pos)
+	frac := pos - float64(lo)
+	return sorted[lo] + time.Duration(frac*float64(sorted[lo+1]-sorted[lo]))
+}
+
+// stdDev returns the population standard deviation of ds or 0 when it is
+// empty.
+func stdDev(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	m := float64(mean(ds))
+	var sum float64
+	for _, d := range ds {
+		diff := float64(d) - m
+		sum += diff * diff
+	}
+	return time.Duration(math.Sqrt(sum / float64(len(ds))))
+}
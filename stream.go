@@ -0,0 +1,93 @@
+package httpstat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// streamMinWidth is the minimum width of a column of StreamWriter, enough
+// for "99999 ms".
+const streamMinWidth = 8
+
+// StreamWriter writes Results as they come, one line per Result in fixed
+// width columns like FormatTable, for a live view of probes with tail -f.
+// The header is written before the first line only. Unlike FormatTable the
+// columns don't adapt to the values, so a line is complete as soon as it is
+// written; a value too wide for its column shifts the rest of its line.
+//
+// A StreamWriter is safe for concurrent use.
+type StreamWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	n      int // the number of lines written
+	widths []int
+}
+
+// NewStreamWriter returns a StreamWriter writing to w. When w has a Flush
+// method, like a bufio.Writer, it is called after each line.
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	labels := streamLabels()
+	widths := make([]int, len(labels))
+	for i, label := range labels {
+		widths[i] = len(label)
+		if widths[i] < streamMinWidth {
+			widths[i] = streamMinWidth
+		}
+	}
+	return &StreamWriter{w: w, widths: widths}
+}
+
+// streamLabels returns the column headers of StreamWriter.
+func streamLabels() []string {
+	labels := []string{"#"}
+	for _, p := range (&Result{}).phaseDurations() {
+		labels = append(labels, phaseLabels[p.name])
+	}
+	return append(labels, "Total")
+}
+
+// Write writes the line of r, after the header for the first one.
+func (s *StreamWriter) Write(r *Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	if s.n == 0 {
+		s.writeLine(&buf, streamLabels())
+	}
+	n := s.n + 1
+	values := []string{fmt.Sprint(n)}
+	for _, p := range r.phaseDurations() {
+		values = append(values, fmt.Sprintf("%d ms", int(p.d/time.Millisecond)))
+	}
+	values = append(values, fmt.Sprintf("%d ms", int(r.total/time.Millisecond)))
+	s.writeLine(&buf, values)
+
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		// Nothing counts as written, the next Write repeats the header
+		// and the number.
+		return err
+	}
+	s.n = n
+	switch f := s.w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
+
+// writeLine writes values right aligned in the columns.
+func (s *StreamWriter) writeLine(buf *bytes.Buffer, values []string) {
+	for i, v := range values {
+		fmt.Fprintf(buf, "%*s", s.widths[i], v)
+		if i < len(values)-1 {
+			buf.WriteString("  ")
+		}
+	}
+	buf.WriteByte('\n')
+}
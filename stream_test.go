@@ -0,0 +1,100 @@
+package httpstat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamWriter(t *testing.T) {
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	sw := NewStreamWriter(bw)
+
+	results := []*Result{
+		{
+			NameLookup:    5 * time.Millisecond,
+			Connect:       15 * time.Millisecond,
+			PreTransfer:   45 * time.Millisecond,
+			StartTransfer: 95 * time.Millisecond,
+			total:         100 * time.Millisecond,
+		},
+		{
+			NameLookup:    120 * time.Millisecond,
+			Connect:       250 * time.Millisecond,
+			PreTransfer:   600 * time.Millisecond,
+			StartTransfer: 1800 * time.Millisecond,
+			total:         12500 * time.Millisecond,
+		},
+		{StartTransfer: 30 * time.Millisecond, total: 31 * time.Millisecond},
+	}
+	for i, r := range results {
+		if err := sw.Write(r); err != nil {
+			t.Fatal("Write failed:", err)
+		}
+		// Every line is flushed as it is written.
+		if got := strings.Count(out.String(), "\n"); got != i+2 {
+			t.Fatalf("got %d lines after %d Results, want %d", got, i+1, i+2)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if strings.Count(out.String(), "DNS Lookup") != 1 || !strings.Contains(lines[0], "DNS Lookup") {
+		t.Fatalf("expect a single header line first:\n%s", out.String())
+	}
+	for i, line := range lines {
+		if len(line) != len(lines[0]) {
+			t.Fatalf("line %d is %d wide, want %d like the header:\n%s", i, len(line), len(lines[0]), out.String())
+		}
+		// The columns are right aligned, so they all end where the
+		// header ones do.
+		for _, end := range []int{strings.Index(lines[0], "Lookup") + len("Lookup"), len(lines[0])} {
+			if line[end-1] == ' ' || end < len(line) && line[end] != ' ' {
+				t.Fatalf("line %d is not aligned with the header:\n%s", i, out.String())
+			}
+		}
+	}
+	if !strings.HasSuffix(lines[2], "12500 ms") {
+		t.Fatalf("second line is %q, want it to end with the total", lines[2])
+	}
+}
+
+// failingWriter fails the first n writes, then writes to w.
+type failingWriter struct {
+	n int
+	w bytes.Buffer
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n > 0 {
+		f.n--
+		return 0, errors.New("write failed")
+	}
+	return f.w.Write(p)
+}
+
+func TestStreamWriter_Error(t *testing.T) {
+	fw := &failingWriter{n: 1}
+	sw := NewStreamWriter(fw)
+
+	r := &Result{StartTransfer: 30 * time.Millisecond, total: 31 * time.Millisecond}
+	if err := sw.Write(r); err == nil {
+		t.Fatal("expect the error of the writer")
+	}
+	if err := sw.Write(r); err != nil {
+		t.Fatal("Write failed:", err)
+	}
+
+	// The failed line isn't counted: the header and the first line are
+	// written again.
+	lines := strings.Split(strings.TrimSuffix(fw.w.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "DNS Lookup") {
+		t.Fatalf("expect the header then a line:\n%s", fw.w.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "1" {
+		t.Fatalf("line is numbered %s, want 1", fields[0])
+	}
+}
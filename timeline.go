@@ -0,0 +1,237 @@
+package httpstat
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// milestone is a point of the request timeline, most of them are httptrace
+// hooks.
+type milestone int
+
+const (
+	milestoneGetConn milestone = iota
+	milestoneDNSStart
+	milestoneDNSDone
+	milestoneConnectStart
+	milestoneConnectDone
+	milestoneTLSHandshakeStart
+	milestoneTLSHandshakeDone
+	milestoneGotConn
+	milestoneWroteHeaders
+	milestoneWroteRequest
+	milestoneGotFirstResponseByte
+	milestoneEnd
+	numMilestones
+)
+
+var milestoneNames = [numMilestones]string{
+	milestoneGetConn:              "GetConn",
+	milestoneDNSStart:             "DNSStart",
+	milestoneDNSDone:              "DNSDone",
+	milestoneConnectStart:         "ConnectStart",
+	milestoneConnectDone:          "ConnectDone",
+	milestoneTLSHandshakeStart:    "TLSHandshakeStart",
+	milestoneTLSHandshakeDone:     "TLSHandshakeDone",
+	milestoneGotConn:              "GotConn",
+	milestoneWroteHeaders:         "WroteHeaders",
+	milestoneWroteRequest:         "WroteRequest",
+	milestoneGotFirstResponseByte: "GotFirstResponseByte",
+	milestoneEnd:                  "End",
+}
+
+// mark records that m was reached at t. Only the first time is kept, so that
+// the timeline of a redirected request is the one of its first hop.
+func (r *Result) mark(m milestone, t time.Time) {
+	if r.timeline[m].IsZero() {
+		r.timeline[m] = t
+	}
+}
+
+// SetStart records t as the time the caller started timing the request,
+// usually before building it. It doesn't change the durations, which start
+// with the first hook, but lets PreTraceGap tell the time spent before.
+func (r *Result) SetStart(t time.Time) {
+	r.callerStart = t
+}
+
+// PreTraceGap returns the time from the start set by SetStart to the first
+// hook, spent in the client before any networking: building the request,
+// CheckRedirect, picking the transport and so on. It is 0 without SetStart
+// or before any hook fired.
+func (r *Result) PreTraceGap() time.Duration {
+	if r.callerStart.IsZero() {
+		return 0
+	}
+	var first time.Time
+	for _, t := range r.timeline {
+		if !t.IsZero() && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if first.IsZero() || first.Before(r.callerStart) {
+		return 0
+	}
+	return first.Sub(r.callerStart)
+}
+
+// LikelyTCPFastOpen reports whether the first write on the connection
+// happened within threshold of the TCP connect completing, which is what a
+// connection using TCP Fast Open (data sent in the SYN) looks like.
+//
+// This is only a heuristic: httptrace can't observe TCP Fast Open and a
+// fast local writer looks the same. The first write is the TLS ClientHello
+// for TLS connections and the request headers otherwise.
+func (r *Result) LikelyTCPFastOpen(threshold time.Duration) bool {
+	connected := r.timeline[milestoneConnectDone]
+	firstWrite := r.timeline[milestoneTLSHandshakeStart]
+	if firstWrite.IsZero() {
+		firstWrite = r.timeline[milestoneWroteHeaders]
+	}
+	if connected.IsZero() || firstWrite.IsZero() {
+		return false
+	}
+	gap := firstWrite.Sub(connected)
+	return gap >= 0 && gap <= threshold
+}
+
+// timelineEntry is a milestone reached at an offset from the start.
+type timelineEntry struct {
+	Name   string        `json:"name"`
+	Offset time.Duration `json:"offset"`
+}
+
+// timelineEntries returns the milestones which were reached in the order
+// they were reached.
+func (r *Result) timelineEntries() []timelineEntry {
+	base := r.timelineBase()
+	var entries []timelineEntry
+	for m, t := range r.timeline {
+		if t.IsZero() {
+			continue
+		}
+		entries = append(entries, timelineEntry{
+			Name:   milestoneNames[m],
+			Offset: t.Sub(base),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Offset < entries[j].Offset
+	})
+	return entries
+}
+
+// HookSequence returns the names of the httptrace hooks in the order they
+// fired, including the ones of every hop of a redirected request, like
+//
+//	[GetConn DNSStart DNSDone ConnectStart ConnectDone GotConn ...]
+//
+// It shows which hooks a connection skipped, which explains phases being 0.
+// It needs WithTraceLog and is nil without it.
+func (r *Result) HookSequence() []string {
+	var names []string
+	for _, e := range r.events.snapshot() {
+		if e.Hook != "End" {
+			names = append(names, e.Hook)
+		}
+	}
+	return names
+}
+
+// LongestGap returns the adjacent milestones of the timeline with the
+// longest time between them, as "From->To" like
+// "WroteRequest->GotFirstResponseByte", and that time. It tells where a
+// request paused without having to read the whole timeline. It returns ""
+// and 0 when fewer than two milestones were reached.
+func (r *Result) LongestGap() (string, time.Duration) {
+	entries := r.timelineEntries()
+	var pair string
+	var longest time.Duration
+	for i := 1; i < len(entries); i++ {
+		if gap := entries[i].Offset - entries[i-1].Offset; pair == "" || gap > longest {
+			pair = entries[i-1].Name + "->" + entries[i].Name
+			longest = gap
+		}
+	}
+	return pair, longest
+}
+
+// timelineBase returns the time timeline offsets are relative to: the start
+// of the request or the earliest milestone when that came first.
+func (r *Result) timelineBase() time.Time {
+	base := r.start
+	for _, t := range r.timeline {
+		if !t.IsZero() && (base.IsZero() || t.Before(base)) {
+			base = t
+		}
+	}
+	return base
+}
+
+// TimelineJSON returns the milestones of the request as a JSON array of
+// objects with the milestone name and its offset from the start of the
+// request in nanoseconds, in the order they were reached:
+//
+//	[{"name":"GetConn","offset":0},{"name":"DNSStart","offset":5123},...]
+//
+// Milestones which were skipped (for example the name lookup on a reused
+// connection) are omitted.
+func (r *Result) TimelineJSON() ([]byte, error) {
+	entries := r.timelineEntries()
+	if entries == nil {
+		entries = []timelineEntry{}
+	}
+	return json.Marshal(entries)
+}
+
+// devToolsFields are the fields of DevToolsTiming in the order the browser
+// reaches them.
+var devToolsFields = [...]string{
+	"dnsStart",
+	"dnsEnd",
+	"connectStart",
+	"connectEnd",
+	"sslStart",
+	"sslEnd",
+	"sendStart",
+	"sendEnd",
+	"receiveHeadersEnd",
+}
+
+// DevToolsTiming returns the timeline in the shape of the timing object of
+// Chrome DevTools (and HAR), with the offsets from the start of the request
+// in milliseconds, so that tools for browser timings can show it. Like in
+// DevTools connectEnd includes the TLS handshake, and fields of phases which
+// didn't happen (for example the name lookup on a reused connection) are -1.
+//
+// receiveHeadersEnd is the time of the first response byte: httptrace has no
+// hook for the end of the headers.
+func (r *Result) DevToolsTiming() map[string]float64 {
+	connectEnd := r.timeline[milestoneTLSHandshakeDone]
+	if connectEnd.IsZero() {
+		connectEnd = r.timeline[milestoneConnectDone]
+	}
+	times := [len(devToolsFields)]time.Time{
+		r.timeline[milestoneDNSStart],
+		r.timeline[milestoneDNSDone],
+		r.timeline[milestoneConnectStart],
+		connectEnd,
+		r.timeline[milestoneTLSHandshakeStart],
+		r.timeline[milestoneTLSHandshakeDone],
+		r.timeline[milestoneGotConn],
+		r.timeline[milestoneWroteRequest],
+		r.timeline[milestoneGotFirstResponseByte],
+	}
+
+	base := r.timelineBase()
+	timing := make(map[string]float64, len(devToolsFields))
+	for i, name := range devToolsFields {
+		if times[i].IsZero() {
+			timing[name] = -1
+			continue
+		}
+		timing[name] = float64(times[i].Sub(base)) / float64(time.Millisecond)
+	}
+	return timing
+}
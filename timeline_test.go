@@ -0,0 +1,248 @@
+package httpstat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLikelyTCPFastOpen(t *testing.T) {
+	connected := time.Now()
+
+	var result Result
+	result.mark(milestoneConnectDone, connected)
+	result.mark(milestoneWroteHeaders, connected.Add(50*time.Microsecond))
+	if !result.LikelyTCPFastOpen(time.Millisecond) {
+		t.Fatal("expect near-zero gap to look like TCP Fast Open")
+	}
+
+	var slow Result
+	slow.mark(milestoneConnectDone, connected)
+	slow.mark(milestoneTLSHandshakeStart, connected.Add(20*time.Millisecond))
+	slow.mark(milestoneWroteHeaders, connected.Add(20*time.Microsecond))
+	if slow.LikelyTCPFastOpen(time.Millisecond) {
+		t.Fatal("expect 20ms gap before the ClientHello not to look like TCP Fast Open")
+	}
+
+	if (&Result{}).LikelyTCPFastOpen(time.Millisecond) {
+		t.Fatal("expect empty result not to look like TCP Fast Open")
+	}
+}
+
+func TestHookSequence(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	res, err := srv.Client().Do(req.WithContext(WithHTTPStat(context.Background(), &result, WithTraceLog())))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+
+	// The URL has an IP address, so there is no name lookup.
+	want := []string{
+		"GetConn",
+		"ConnectStart",
+		"ConnectDone",
+		"TLSHandshakeStart",
+		"TLSHandshakeDone",
+		"GotConn",
+		"WroteHeaders",
+		"WroteRequest",
+		"GotFirstResponseByte",
+	}
+	if got := result.HookSequence(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("HookSequence is %v, want %v", got, want)
+	}
+
+	if got := (&Result{}).HookSequence(); got != nil {
+		t.Fatalf("HookSequence of empty result is %v, want nil", got)
+	}
+
+	// Without WithTraceLog the hooks record nothing.
+	untraced := do(t, srv.Client(), srv.URL)
+	if untraced.events != nil || untraced.HookSequence() != nil {
+		t.Fatal("expect no hook calls to be recorded without WithTraceLog")
+	}
+}
+
+func TestLongestGap(t *testing.T) {
+	start := time.Now()
+	var result Result
+	for _, m := range []struct {
+		m  milestone
+		at time.Duration
+	}{
+		{milestoneGetConn, 0},
+		{milestoneDNSStart, time.Millisecond},
+		{milestoneDNSDone, 5 * time.Millisecond},
+		{milestoneConnectStart, 6 * time.Millisecond},
+		{milestoneConnectDone, 16 * time.Millisecond},
+		{milestoneGotConn, 17 * time.Millisecond},
+		{milestoneWroteHeaders, 18 * time.Millisecond},
+		{milestoneWroteRequest, 18 * time.Millisecond},
+		{milestoneGotFirstResponseByte, 918 * time.Millisecond},
+		{milestoneEnd, 920 * time.Millisecond},
+	} {
+		result.mark(m.m, start.Add(m.at))
+	}
+
+	pair, gap := result.LongestGap()
+	if pair != "WroteRequest->GotFirstResponseByte" || gap != 900*time.Millisecond {
+		t.Fatalf("LongestGap is %q, %s, want WroteRequest->GotFirstResponseByte, 900ms", pair, gap)
+	}
+
+	if pair, gap := (&Result{}).LongestGap(); pair != "" || gap != 0 {
+		t.Fatalf("LongestGap of empty result is %q, %s", pair, gap)
+	}
+}
+
+func TestTimelineJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	result := do(t, DefaultClient(), srv.URL)
+	b, err := result.TimelineJSON()
+	if err != nil {
+		t.Fatal("TimelineJSON failed:", err)
+	}
+
+	var entries []struct {
+		Name   string `json:"name"`
+		Offset int64  `json:"offset"`
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		t.Fatal("Unmarshal failed:", err)
+	}
+
+	// Plain HTTP to an IP skips the lookup and the handshake.
+	want := []string{
+		"GetConn", "ConnectStart", "ConnectDone", "GotConn",
+		"WroteHeaders", "WroteRequest", "GotFirstResponseByte", "End",
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("timeline is %s, want milestones %v", b, want)
+	}
+	for i, e := range entries {
+		if e.Name != want[i] {
+			t.Fatalf("milestone %d is %s, want %s", i, e.Name, want[i])
+		}
+		if i > 0 && e.Offset < entries[i-1].Offset {
+			t.Fatalf("expect offsets to be monotonic: %s", b)
+		}
+	}
+	if entries[0].Offset != 0 {
+		t.Fatalf("first offset is %d, want 0", entries[0].Offset)
+	}
+
+	if b, _ := (&Result{}).TimelineJSON(); string(b) != "[]" {
+		t.Fatalf("timeline of empty result is %s, want []", b)
+	}
+}
+
+func TestDevToolsTiming(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	result := Result{start: start}
+	result.mark(milestoneGetConn, at(0))
+	result.mark(milestoneDNSStart, at(1))
+	result.mark(milestoneDNSDone, at(5))
+	result.mark(milestoneConnectStart, at(5))
+	result.mark(milestoneConnectDone, at(15))
+	result.mark(milestoneTLSHandshakeStart, at(15))
+	result.mark(milestoneTLSHandshakeDone, at(40))
+	result.mark(milestoneGotConn, at(40))
+	result.mark(milestoneWroteRequest, at(41))
+	result.mark(milestoneGotFirstResponseByte, at(90))
+
+	want := map[string]float64{
+		"dnsStart":          1,
+		"dnsEnd":            5,
+		"connectStart":      5,
+		"connectEnd":        40,
+		"sslStart":          15,
+		"sslEnd":            40,
+		"sendStart":         40,
+		"sendEnd":           41,
+		"receiveHeadersEnd": 90,
+	}
+	got := result.DevToolsTiming()
+	if len(got) != len(want) {
+		t.Fatalf("DevToolsTiming is %v, want %v", got, want)
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Fatalf("%s is %v, want %v", name, got[name], v)
+		}
+	}
+
+	// The start and end of a phase come before the next phase ends.
+	order := []string{"dnsStart", "dnsEnd", "connectStart", "sslStart", "sslEnd", "connectEnd", "sendStart", "sendEnd", "receiveHeadersEnd"}
+	for i := 1; i < len(order); i++ {
+		if got[order[i]] < got[order[i-1]] {
+			t.Fatalf("%s (%v) is before %s (%v)", order[i], got[order[i]], order[i-1], got[order[i-1]])
+		}
+	}
+
+	// A reused plain connection skips the lookup, connect and handshake.
+	reused := Result{start: start}
+	reused.mark(milestoneGetConn, at(0))
+	reused.mark(milestoneGotConn, at(0))
+	reused.mark(milestoneWroteRequest, at(1))
+	reused.mark(milestoneGotFirstResponseByte, at(10))
+	got = reused.DevToolsTiming()
+	for _, name := range []string{"dnsStart", "dnsEnd", "connectStart", "connectEnd", "sslStart", "sslEnd"} {
+		if got[name] != -1 {
+			t.Fatalf("%s of a reused connection is %v, want -1", name, got[name])
+		}
+	}
+	if got["receiveHeadersEnd"] != 10 {
+		t.Fatalf("receiveHeadersEnd is %v, want 10", got["receiveHeadersEnd"])
+	}
+}
+
+func TestPreTraceGap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var result Result
+	result.SetStart(time.Now())
+	// Client side work before the request is sent.
+	time.Sleep(20 * time.Millisecond)
+	res, err := DefaultClient().Do(NewRequest(t, srv.URL, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+
+	if got := result.PreTraceGap(); got < 20*time.Millisecond {
+		t.Fatalf("PreTraceGap is %s, want at least 20ms", got)
+	}
+
+	var unset Result
+	unset.mark(milestoneGetConn, time.Now())
+	if got := unset.PreTraceGap(); got != 0 {
+		t.Fatalf("PreTraceGap without SetStart is %s, want 0", got)
+	}
+}
@@ -0,0 +1,99 @@
+package httpstat
+
+import (
+	"crypto/x509"
+	"net"
+	"strings"
+)
+
+// TLSVersion returns the TLS version negotiated by the handshake, one of the
+// tls.VersionTLS constants. It is 0 when no handshake was traced.
+func (r *Result) TLSVersion() uint16 {
+	return r.tlsVersion
+}
+
+// TLSResumed reports whether the TLS session of the connection was resumed
+// from an earlier one, which saves a round trip and the certificate
+// exchange. For a reused connection it tells how the connection was set up.
+func (r *Result) TLSResumed() bool {
+	return r.tlsResumed
+}
+
+// NegotiatedProtocol returns the protocol agreed on with ALPN during the TLS
+// handshake, like "h2" or "http/1.1". It is "" when no handshake was traced
+// or the server didn't pick one.
+func (r *Result) NegotiatedProtocol() string {
+	return r.alpn
+}
+
+// ALPNMatched reports whether the protocol negotiated with ALPN is the one
+// the client prefers, the first one given to WithNextProtos. False means the
+// server picked another protocol, or none, for example HTTP/1.1 when HTTP/2
+// was expected. It needs WithNextProtos and a traced handshake.
+func (r *Result) ALPNMatched() bool {
+	if len(r.cfg.nextProtos) == 0 {
+		return false
+	}
+	return r.alpn == r.cfg.nextProtos[0]
+}
+
+// TLSDowngraded reports whether the server negotiated a TLS version lower
+// than the highest one offered by the client, which usually means the server
+// is misconfigured. It needs WithTLSVersions and a traced handshake.
+func (r *Result) TLSDowngraded() bool {
+	if r.tlsVersion == 0 || r.cfg.tlsMax == 0 {
+		return false
+	}
+	return r.tlsVersion < r.cfg.tlsMax
+}
+
+// TimedVerify wraps fn, a tls.Config.VerifyPeerCertificate callback, to add
+// the time it takes to CertVerifyTime of r. That time is part of the TLS
+// handshake, this tells how much of it went to custom verification like CRL
+// or OCSP fetches. Plug it into the tls.Config of the transport sending the
+// request traced by r:
+//
+//	transport.TLSClientConfig.VerifyPeerCertificate = httpstat.TimedVerify(verify, &result)
+//
+// As the callback belongs to the transport and not to a request, use a
+// transport per traced request, or at least one request at a time.
+//
+// A nil fn, the default of tls.Config, means no custom verification, so
+// TimedVerify returns nil.
+func TimedVerify(fn func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error, r *Result) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if fn == nil {
+		return nil
+	}
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		start := r.now()
+		err := fn(rawCerts, verifiedChains)
+		r.CertVerifyTime += r.now().Sub(start)
+		return err
+	}
+}
+
+// remoteAlertPrefix starts the message of an error caused by a TLS alert
+// from the peer.
+const remoteAlertPrefix = "remote error: tls: "
+
+// tlsAlert returns the description of the TLS alert received from the peer
+// which caused err, or "" when it wasn't one. The crypto/tls package reports
+// the alert as a net.OpError with the "remote error" operation; errors which
+// only kept its message are recognized by it.
+func tlsAlert(err error) string {
+	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "remote error" && opErr.Err != nil {
+		return strings.TrimPrefix(opErr.Err.Error(), "tls: ")
+	}
+	if msg := err.Error(); strings.Contains(msg, remoteAlertPrefix) {
+		return msg[strings.Index(msg, remoteAlertPrefix)+len(remoteAlertPrefix):]
+	}
+	return ""
+}
+
+// recordTLSAlert sets TLSAlert from err unless an alert was already
+// recorded.
+func (r *Result) recordTLSAlert(err error) {
+	if r.TLSAlert == "" {
+		r.TLSAlert = tlsAlert(err)
+	}
+}
@@ -0,0 +1,194 @@
+package httpstat
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// tlsServer starts a TLS server which supports up to maxVersion.
+func tlsServer(maxVersion uint16) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.TLS = &tls.Config{MaxVersion: maxVersion}
+	srv.StartTLS()
+	return srv
+}
+
+// doTLS sends a traced GET to srv offering TLS 1.2 and 1.3.
+func doTLS(t *testing.T, srv *httptest.Server, opts ...Option) *Result {
+	client := srv.Client()
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig.MinVersion = tls.VersionTLS12
+	transport.TLSClientConfig.MaxVersion = tls.VersionTLS13
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result, opts...)
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+	return &result
+}
+
+func TestTLSDowngraded(t *testing.T) {
+	offered := WithTLSVersions(tls.VersionTLS12, tls.VersionTLS13)
+
+	srv12 := tlsServer(tls.VersionTLS12)
+	defer srv12.Close()
+	result := doTLS(t, srv12, offered)
+	if result.TLSVersion() != tls.VersionTLS12 {
+		t.Fatalf("TLSVersion is %x, want %x", result.TLSVersion(), tls.VersionTLS12)
+	}
+	if !result.TLSDowngraded() {
+		t.Fatal("expect TLS 1.2 only server to be a downgrade")
+	}
+
+	srv13 := tlsServer(tls.VersionTLS13)
+	defer srv13.Close()
+	if result := doTLS(t, srv13, offered); result.TLSDowngraded() {
+		t.Fatal("expect TLS 1.3 server not to be a downgrade")
+	}
+
+	// Without the offered versions nothing is flagged.
+	if result := doTLS(t, srv12); result.TLSDowngraded() {
+		t.Fatal("expect no downgrade without WithTLSVersions")
+	}
+}
+
+func TestTimedVerify(t *testing.T) {
+	srv := tlsServer(tls.VersionTLS13)
+	defer srv.Close()
+
+	var result Result
+	verified := false
+	slow := func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		time.Sleep(50 * time.Millisecond)
+		verified = len(verifiedChains) > 0
+		return nil
+	}
+	client := srv.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.VerifyPeerCertificate = TimedVerify(slow, &result)
+
+	res, err := client.Do(NewRequest(t, srv.URL, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	result.End(time.Now())
+
+	if !verified {
+		t.Fatal("expect the wrapped callback to be called with the verified chains")
+	}
+	handshake := result.Durations()["TLSHandshake"]
+	if result.CertVerifyTime < 50*time.Millisecond || result.CertVerifyTime > handshake {
+		t.Fatalf("CertVerifyTime is %s, want at least 50ms and at most the handshake %s",
+			result.CertVerifyTime, handshake)
+	}
+
+	if TimedVerify(nil, &result) != nil {
+		t.Fatal("expect TimedVerify of a nil callback to be nil")
+	}
+}
+
+func TestTLSAlert(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	// The client has no certificate, so the server fails the handshake
+	// with an alert: bad_certificate or, in newer versions of Go,
+	// handshake_failure.
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12, ClientAuth: tls.RequireAnyClientCert}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	if _, err := srv.Client().Do(req.WithContext(WithHTTPStat(context.Background(), &result))); err == nil {
+		t.Fatal("expect the handshake to fail")
+	}
+	if result.TLSAlert != "bad certificate" && result.TLSAlert != "handshake failure" {
+		t.Fatalf("TLSAlert is %q, want the alert of the server", result.TLSAlert)
+	}
+
+	for _, err := range []error{
+		errors.New("dial tcp 192.0.2.1:443: connect: connection refused"),
+		io.EOF,
+	} {
+		if got := tlsAlert(err); got != "" {
+			t.Fatalf("tlsAlert of %q is %q, want \"\"", err, got)
+		}
+	}
+	if got := tlsAlert(fmt.Errorf("get: %v", "remote error: tls: handshake failure")); got != "handshake failure" {
+		t.Fatalf("tlsAlert of a wrapped message is %q, want %q", got, "handshake failure")
+	}
+}
+
+func TestALPNMatched(t *testing.T) {
+	h2 := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	StartHTTP2(h2)
+	defer h2.Close()
+	h1 := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer h1.Close()
+
+	// The client of the HTTP/2 server prefers h2. The servers share their
+	// certificate, so it can talk to both.
+	client := h2.Client()
+	for _, tc := range []struct {
+		srv  *httptest.Server
+		want bool
+	}{
+		{h2, true},
+		{h1, false},
+	} {
+		req, err := http.NewRequest("GET", tc.srv.URL, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed:", err)
+		}
+		var result Result
+		ctx := WithHTTPStat(context.Background(), &result, WithNextProtos("h2", "http/1.1"))
+		res, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal("client.Do failed:", err)
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		if got := result.ALPNMatched(); got != tc.want {
+			t.Fatalf("ALPNMatched with %q negotiated is %v, want %v", result.NegotiatedProtocol(), got, tc.want)
+		}
+	}
+
+	if (&Result{alpn: "h2"}).ALPNMatched() {
+		t.Fatal("expect no match without WithNextProtos")
+	}
+}
@@ -0,0 +1,107 @@
+package httpstat
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Transport is an http.RoundTripper which traces every request it sends.
+//
+// The httptrace hooks fire in the innermost http.Transport, which finds them
+// in the context of the request. When RoundTrippers are stacked (auth, retry,
+// logging and so on) put Transport outermost so that every layer below sees
+// the traced context:
+//
+//	client := &http.Client{
+//		Transport: &httpstat.Transport{
+//			Base:     auth(retry(http.DefaultTransport)),
+//			OnResult: func(req *http.Request, r *httpstat.Result) { ... },
+//		},
+//	}
+//
+// Layers must derive the requests they send from the one they were given
+// (req.WithContext(req.Context()), req.Clone and so on). A layer which sends a
+// request with a new context drops the trace.
+type Transport struct {
+	// Base sends the requests. http.DefaultTransport is used when nil.
+	Base http.RoundTripper
+
+	// Options are given to WithHTTPStat for every request.
+	Options []Option
+
+	// OnResult is called with the Result of a request once its response
+	// body is read to EOF or closed, or when the request failed. It may be
+	// nil.
+	OnResult func(req *http.Request, r *Result)
+
+	// Stats counts the requests sent. It may be nil and may be shared by
+	// several Transports.
+	Stats *StatsCollector
+}
+
+// RoundTrip implements http.RoundTripper. Requests which are already traced
+// by the caller are sent as they are, the caller owns their Result.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if r := fromContext(req.Context()); r != nil {
+		res, err := base.RoundTrip(req)
+		t.Stats.record(r, err)
+		if err != nil {
+			r.SetError(err)
+		}
+		return res, err
+	}
+
+	r := &Result{}
+	req = req.WithContext(WithHTTPStat(req.Context(), r, t.Options...))
+	r.WrapRequestBody(req)
+	res, err := base.RoundTrip(req)
+	t.Stats.record(r, err)
+	if err != nil {
+		r.SetError(err)
+		r.End(r.now())
+		t.done(req, r)
+		return nil, err
+	}
+
+	r.SetResponse(res)
+	res.Body = &endBody{
+		ReadCloser: r.WrapBody(res.Body),
+		end: func() {
+			r.End(r.now())
+			t.done(req, r)
+		},
+	}
+	return res, nil
+}
+
+func (t *Transport) done(req *http.Request, r *Result) {
+	if t.OnResult != nil {
+		t.OnResult(req, r)
+	}
+}
+
+// endBody calls end once, at EOF or on Close whichever comes first.
+type endBody struct {
+	io.ReadCloser
+	end  func()
+	once sync.Once
+}
+
+func (b *endBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.end)
+	}
+	return n, err
+}
+
+func (b *endBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.end)
+	return err
+}
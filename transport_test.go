@@ -0,0 +1,165 @@
+package httpstat
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// headerLayer is a middleware RoundTripper which sends a copy of the request
+// with an extra header, like an auth layer would.
+func headerLayer(base http.RoundTripper, key, value string) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		clone := req.WithContext(req.Context())
+		clone.Header = http.Header{}
+		for k, v := range req.Header {
+			clone.Header[k] = v
+		}
+		clone.Header.Set(key, value)
+		return base.RoundTrip(clone)
+	})
+}
+
+func TestTransport_Chain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" || r.Header.Get("X-Request-Id") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	results := make(chan *Result, 1)
+	client := &http.Client{
+		Transport: &Transport{
+			Base: headerLayer(headerLayer(DefaultTransport(),
+				"Authorization", "Bearer token"),
+				"X-Request-Id", "1"),
+			OnResult: func(req *http.Request, r *Result) {
+				results <- r
+			},
+		},
+	}
+
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal("client.Get failed:", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status is %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+
+	r := <-results
+	if r.Connect <= 0 || r.StartTransfer < 10*time.Millisecond {
+		t.Fatalf("expect trace to reach the inner transport: %+v", *r)
+	}
+	if r.CumulativeOffsets().Complete < r.StartTransfer {
+		t.Fatalf("expect End to be called: %+v", *r)
+	}
+	if r.RemoteIP() != "127.0.0.1" {
+		t.Fatalf("RemoteIP is %q, want 127.0.0.1", r.RemoteIP())
+	}
+}
+
+func TestTransport_Traced(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	called := false
+	client := &http.Client{
+		Transport: &Transport{
+			Base: DefaultTransport(),
+			OnResult: func(req *http.Request, r *Result) {
+				called = true
+			},
+		},
+	}
+
+	var result Result
+	res, err := client.Do(NewRequest(t, srv.URL, &result))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if called {
+		t.Fatal("expect request traced by the caller not to be reported")
+	}
+	if result.StartTransfer <= 0 {
+		t.Fatal("expect caller's Result to be recorded")
+	}
+}
+
+func TestTransport_Error(t *testing.T) {
+	var got *Result
+	client := &http.Client{
+		Transport: &Transport{
+			Base: DefaultTransport(),
+			OnResult: func(req *http.Request, r *Result) {
+				got = r
+			},
+		},
+	}
+
+	if _, err := client.Get("http://127.0.0.1:0/"); err == nil {
+		t.Fatal("expect a request to port 0 to fail")
+	}
+	if got == nil || got.Err() == nil {
+		t.Fatal("expect the Result of a failed request to have its error")
+	}
+	if label := got.StatusLabel(); label != "🔴 failed" {
+		t.Fatalf("StatusLabel is %q, want the failed one", label)
+	}
+}
+
+func TestTransport_Clock(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	frozen := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var got *Result
+	client := &http.Client{
+		Transport: &Transport{
+			Base:    DefaultTransport(),
+			Options: []Option{WithClock(func() time.Time { return frozen })},
+			OnResult: func(req *http.Request, r *Result) {
+				got = r
+			},
+		},
+	}
+
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal("client.Get failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if got == nil {
+		t.Fatal("expect the Result to be reported")
+	}
+	if got.total != 0 {
+		t.Fatalf("total is %v with a frozen clock, want 0", got.total)
+	}
+}
@@ -0,0 +1,116 @@
+package httpstat
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Phases of a request in the order they happen. The zero value means no
+// hook has fired yet.
+const (
+	phaseNone int32 = iota
+	phaseDNSLookup
+	phaseTCPConnection
+	phaseTLSHandshake
+	phaseServerProcessing
+	phaseContentTransfer
+	phaseDone
+)
+
+var phaseNames = [...]string{
+	phaseNone:             "",
+	phaseDNSLookup:        "DNSLookup",
+	phaseTCPConnection:    "TCPConnection",
+	phaseTLSHandshake:     "TLSHandshake",
+	phaseServerProcessing: "ServerProcessing",
+	phaseContentTransfer:  "ContentTransfer",
+	phaseDone:             "",
+}
+
+// phaseBits are the low bits of Result.phase holding the phase, the time
+// it was entered loses them.
+const phaseBits = 3
+
+// enterPhase records that the request moved on to phase p at t and reports
+// the phase in progress until then to the OnPhase callback. The hooks of
+// concurrent dials enter phases at the same time, so the phase and its time
+// are swapped as one word and each phase is reported by a single caller.
+func (r *Result) enterPhase(p int32, t time.Time) {
+	next := t.UnixNano()&^(1<<phaseBits-1) | int64(p)
+	prev := atomic.SwapInt64(&r.phase, next)
+
+	if onPhase := r.cfg.onPhase; onPhase != nil {
+		prevPhase, at := unpackPhase(prev)
+		if prevPhase != phaseNone && prevPhase != p {
+			r.call(func() { onPhase(phaseNames[prevPhase], t.Sub(at)) })
+		}
+	}
+}
+
+// currentPhase returns the phase in progress and when it was entered.
+func (r *Result) currentPhase() (int32, time.Time) {
+	return unpackPhase(atomic.LoadInt64(&r.phase))
+}
+
+// unpackPhase returns the phase and the time of a Result.phase word.
+func unpackPhase(v int64) (int32, time.Time) {
+	p := int32(v & (1<<phaseBits - 1))
+	at := v &^ (1<<phaseBits - 1)
+	if at == 0 {
+		return p, time.Time{}
+	}
+	return p, time.Unix(0, at)
+}
+
+// InProgress reports whether the request is running: a hook fired but End
+// wasn't called yet. It is safe to call from another goroutine while the
+// request runs, for example to poll a Result for a live view.
+func (r *Result) InProgress() bool {
+	p, _ := r.currentPhase()
+	return p != phaseNone && p != phaseDone
+}
+
+// WatchStall starts a goroutine which watches r while the request is running
+// and calls onStall with the name of the phase in progress when no phase has
+// completed for maxGap. onStall is called at most once per stalled phase.
+// Watching stops when ctx is done or End is called.
+//
+// This catches hung connections which http.Client.Timeout only reports as a
+// whole. The phase is "" when the request stalled before any hook fired.
+// The gap is measured with the clock of r, so options like WithClock must be
+// given to WithHTTPStat before WatchStall is called.
+func WatchStall(ctx context.Context, r *Result, maxGap time.Duration, onStall func(phase string)) {
+	interval := maxGap / 4
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		watchStart := r.now()
+		reported := time.Time{}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				now := r.now()
+				p, at := r.currentPhase()
+				if p == phaseDone {
+					return
+				}
+				if at.IsZero() {
+					at = watchStart
+				}
+				if now.Sub(at) < maxGap || at.Equal(reported) {
+					continue
+				}
+				reported = at
+				onStall(phaseNames[p])
+			}
+		}
+	}()
+}
@@ -0,0 +1,239 @@
+package httpstat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchStall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stall while the client waits for the first response byte.
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var result Result
+	stalled := make(chan string, 10)
+	WatchStall(ctx, &result, 50*time.Millisecond, func(phase string) {
+		stalled <- phase
+	})
+
+	req := NewRequest(t, srv.URL, &result)
+	res, err := DefaultClient().Do(req)
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, res.Body); err != nil {
+		t.Fatal("io.Copy failed:", err)
+	}
+	res.Body.Close()
+	result.End(time.Now())
+
+	select {
+	case phase := <-stalled:
+		if phase != "ServerProcessing" {
+			t.Fatalf("stalled phase is %q, want %q", phase, "ServerProcessing")
+		}
+	default:
+		t.Fatal("expect onStall to be called")
+	}
+}
+
+func TestWatchStall_NoStall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var result Result
+	called := make(chan string, 1)
+	WatchStall(ctx, &result, 50*time.Millisecond, func(phase string) {
+		called <- phase
+	})
+	cancel()
+
+	select {
+	case phase := <-called:
+		t.Fatalf("expect onStall not to be called, got %q", phase)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchStall_Clock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	var result Result
+	WithHTTPStat(ctx, &result, WithClock(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}))
+	result.enterPhase(phaseServerProcessing, now)
+
+	stalled := make(chan string, 10)
+	WatchStall(ctx, &result, 20*time.Millisecond, func(phase string) {
+		stalled <- phase
+	})
+
+	// The clock of the Result doesn't move, however long the wall clock
+	// waits.
+	select {
+	case phase := <-stalled:
+		t.Fatalf("expect no stall while the clock stands still, got %q", phase)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mu.Lock()
+	now = now.Add(time.Second)
+	mu.Unlock()
+	select {
+	case phase := <-stalled:
+		if phase != "ServerProcessing" {
+			t.Fatalf("stalled phase is %q, want %q", phase, "ServerProcessing")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect onStall to be called once the clock moved past maxGap")
+	}
+}
+
+func TestCancelledAtPhase(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first chunk")
+		if r.URL.Path != "/stall" {
+			return
+		}
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequest("GET", srv.URL+"/stall", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed:", err)
+	}
+	var result Result
+	res, err := DefaultClient().Do(req.WithContext(WithHTTPStat(ctx, &result)))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	buf := make([]byte, len("first chunk"))
+	if _, err := io.ReadFull(res.Body, buf); err != nil {
+		t.Fatal("ReadFull failed:", err)
+	}
+	cancel()
+	if _, err := io.Copy(ioutil.Discard, res.Body); err == nil {
+		t.Fatal("expect the read after cancel to fail")
+	}
+	res.Body.Close()
+	result.EndContext(ctx, time.Now())
+
+	if got := result.CancelledAtPhase(); got != "ContentTransfer" {
+		t.Fatalf("CancelledAtPhase is %q, want %q", got, "ContentTransfer")
+	}
+
+	// A request which completes isn't cancelled.
+	var done Result
+	res, err = DefaultClient().Do(NewRequest(t, srv.URL, &done))
+	if err != nil {
+		t.Fatal("client.Do failed:", err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	done.EndContext(context.Background(), time.Now())
+	if got := done.CancelledAtPhase(); got != "" {
+		t.Fatalf("CancelledAtPhase is %q, want \"\"", got)
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var result Result
+	if result.InProgress() {
+		t.Fatal("expect a request which didn't start not to be in progress")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := DefaultClient().Do(NewRequest(t, srv.URL, &result))
+		if err != nil {
+			t.Error("client.Do failed:", err)
+			return
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		result.End(time.Now())
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !result.InProgress() {
+		if time.Now().After(deadline) {
+			t.Fatal("expect the request to be in progress")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+
+	if result.InProgress() {
+		t.Fatal("expect the request not to be in progress after End")
+	}
+}
+
+func TestEnterPhase_Concurrent(t *testing.T) {
+	// The ConnectStart hooks of happy eyeballs dials are called at the same
+	// time, the DNSLookup before them must be reported once.
+	var (
+		mu       sync.Mutex
+		reported []string
+	)
+	var result Result
+	WithOnPhase(func(phase string, d time.Duration) {
+		mu.Lock()
+		reported = append(reported, phase)
+		mu.Unlock()
+	})(&result.cfg)
+
+	start := time.Now()
+	result.enterPhase(phaseDNSLookup, start)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.enterPhase(phaseTCPConnection, start.Add(time.Millisecond))
+		}()
+	}
+	wg.Wait()
+
+	if len(reported) != 1 || reported[0] != "DNSLookup" {
+		t.Fatalf("reported phases are %v, want [DNSLookup]", reported)
+	}
+	// The time loses the nanoseconds the phase is packed in.
+	p, at := result.currentPhase()
+	if d := at.Sub(start); p != phaseTCPConnection || d > time.Millisecond || d < time.Millisecond-8 {
+		t.Fatalf("current phase is %d entered after %s, want TCPConnection after 1ms", p, d)
+	}
+}
@@ -0,0 +1,92 @@
+package httpstat
+
+import "time"
+
+// Window keeps the last Results added to it, for statistics over a moving
+// window such as a live latency display. The phase names of its methods
+// are the ones of the phases, like "TLSHandshake", or "Total".
+type Window struct {
+	results []*Result
+	next    int // index of the oldest Result once the window is full
+}
+
+// NewWindow returns a Window of the last size Results. size less than 1 is
+// treated as 1.
+func NewWindow(size int) *Window {
+	if size < 1 {
+		size = 1
+	}
+	return &Window{results: make([]*Result, 0, size)}
+}
+
+// Add adds r to the window, evicting the oldest Result when it is full.
+func (w *Window) Add(r *Result) {
+	if len(w.results) < cap(w.results) {
+		w.results = append(w.results, r)
+		return
+	}
+	w.results[w.next] = r
+	w.next = (w.next + 1) % len(w.results)
+}
+
+// Len returns the number of Results in the window.
+func (w *Window) Len() int {
+	return len(w.results)
+}
+
+// Mean returns the mean duration of phase over the window.
+func (w *Window) Mean(phase string) time.Duration {
+	return mean(phaseValues(w.results, phase))
+}
+
+// Percentile returns the p-th percentile (0-100) duration of phase over the
+// window.
+func (w *Window) Percentile(phase string, p float64) time.Duration {
+	return percentile(phaseValues(w.results, phase), p)
+}
+
+// EWMA keeps an exponentially weighted moving average of the duration of
+// each phase of the Results added to it, for a smoothed live latency
+// display. Unlike Window it keeps no Results and follows a change of the
+// latency at a speed set by its decay. The phase names are the ones of
+// PhaseNames, or "Total".
+//
+// An EWMA is not safe for concurrent use.
+type EWMA struct {
+	alpha  float64
+	values map[string]float64
+}
+
+// NewEWMA returns an EWMA where each Result added weighs alpha in the
+// average and the average before it 1-alpha: a high alpha follows changes
+// quickly, a low one smooths more. alpha outside (0, 1] is treated as 1,
+// which keeps the last Result only.
+func NewEWMA(alpha float64) *EWMA {
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	return &EWMA{alpha: alpha, values: make(map[string]float64)}
+}
+
+// Add adds the durations of r to the averages. The first Result sets them.
+func (e *EWMA) Add(r *Result) {
+	e.add("Total", r.total)
+	for _, p := range r.phaseDurations() {
+		e.add(p.name, p.d)
+	}
+}
+
+func (e *EWMA) add(phase string, d time.Duration) {
+	v, ok := e.values[phase]
+	if !ok {
+		e.values[phase] = float64(d)
+		return
+	}
+	e.values[phase] = e.alpha*float64(d) + (1-e.alpha)*v
+}
+
+// Value returns the moving average of phase. It is 0 for an unknown phase or
+// before any Result was added.
+func (e *EWMA) Value(phase string) time.Duration {
+	return time.Duration(e.values[phase])
+}
@@ -0,0 +1,90 @@
+package httpstat
+
+import (
+	"testing"
+	"time"
+)
+
+// totalResult returns a Result whose total is ms milliseconds.
+func totalResult(ms int) *Result {
+	return &Result{total: time.Duration(ms) * time.Millisecond}
+}
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(3)
+	for _, ms := range []int{1000, 10, 20, 30} {
+		w.Add(totalResult(ms))
+	}
+
+	if got := w.Len(); got != 3 {
+		t.Fatalf("Len is %d, want 3", got)
+	}
+	// 1000ms was evicted.
+	if got, want := w.Mean("Total"), 20*time.Millisecond; got != want {
+		t.Fatalf("Mean is %s, want %s", got, want)
+	}
+	if got, want := w.Percentile("Total", 100), 30*time.Millisecond; got != want {
+		t.Fatalf("p100 is %s, want %s", got, want)
+	}
+	if got, want := w.Percentile("Total", 50), 20*time.Millisecond; got != want {
+		t.Fatalf("p50 is %s, want %s", got, want)
+	}
+
+	w.Add(totalResult(60))
+	if got, want := w.Mean("Total"), 110*time.Millisecond/3; got != want {
+		t.Fatalf("Mean is %s, want %s", got, want)
+	}
+}
+
+func TestWindow_Phase(t *testing.T) {
+	w := NewWindow(2)
+	w.Add(&Result{NameLookup: 10 * time.Millisecond})
+	w.Add(&Result{NameLookup: 30 * time.Millisecond})
+
+	if got, want := w.Mean("DNSLookup"), 20*time.Millisecond; got != want {
+		t.Fatalf("Mean is %s, want %s", got, want)
+	}
+	if got := w.Mean("Teleport"); got != 0 {
+		t.Fatalf("Mean of unknown phase is %s, want 0", got)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	e := NewEWMA(0.3)
+	if got := e.Value("Total"); got != 0 {
+		t.Fatalf("Value before any Result is %s, want 0", got)
+	}
+	for i := 0; i < 10; i++ {
+		e.Add(totalResult(100))
+	}
+	if got := e.Value("Total"); got != 100*time.Millisecond {
+		t.Fatalf("Value of a steady latency is %s, want 100ms", got)
+	}
+
+	// After a step to 500ms the average moves toward it with every Result.
+	prev := e.Value("Total")
+	for i := 0; i < 20; i++ {
+		e.Add(totalResult(500))
+		got := e.Value("Total")
+		if got <= prev || got > 500*time.Millisecond {
+			t.Fatalf("Value after %d Results of the new level is %s, previous %s", i+1, got, prev)
+		}
+		prev = got
+	}
+	if prev < 495*time.Millisecond {
+		t.Fatalf("Value is %s, want it converged to about 500ms", prev)
+	}
+	if got := e.Value("ContentTransfer"); got != prev {
+		t.Fatalf("ContentTransfer average is %s, want the total of Results without setup %s", got, prev)
+	}
+	if got := e.Value("Teleport"); got != 0 {
+		t.Fatalf("Value of an unknown phase is %s, want 0", got)
+	}
+
+	last := NewEWMA(0)
+	last.Add(totalResult(100))
+	last.Add(totalResult(300))
+	if got := last.Value("Total"); got != 300*time.Millisecond {
+		t.Fatalf("Value with an out of range decay is %s, want the last total", got)
+	}
+}